@@ -24,6 +24,7 @@ type analogPin struct {
 	outputOffset uint16
 	inputOffset  uint16
 	info         analogInfo
+	generation   uint64 // configuration generation of the chip when the pin was initialized
 }
 
 type analogInfo struct {
@@ -34,10 +35,10 @@ type analogInfo struct {
 
 func initializeAnalogPin(pin SPIVariable, g *gpioChip) (*analogPin, error) {
 	analogPin := analogPin{Name: str32(pin.strVarName), Address: pin.i16uAddress, Length: pin.i16uLength, ControlChip: g}
-	aio, err := findDevice(analogPin.Address, g.aioDevices)
+	aio, err := findDevice(analogPin.Address, g.getAIODevices())
 	if err != nil {
 		analogPin.ControlChip.logger.Debug("pin is not from a supported GPIO board")
-		return nil, err
+		return nil, g.explainMissingDevice(analogPin.Name, "analog pin", analogPin.Address, err)
 	}
 
 	// store the input & output offsets of the board for quick reference
@@ -48,12 +49,8 @@ func initializeAnalogPin(pin SPIVariable, g *gpioChip) (*analogPin, error) {
 		analogInputNumber := (analogPin.Address - analogPin.inputOffset) / 2                     // results in 0, 1, 2, or 3
 		inputRangeAddress := analogInputNumber*7 + analogInputMemAddress + analogPin.inputOffset // results in pin 24, 31, 38, or 45
 		bufInputRange := make([]byte, 1)
-		n, err := analogPin.ControlChip.fileHandle.ReadAt(bufInputRange, int64(inputRangeAddress))
-		if err != nil {
-			return nil, fmt.Errorf("failed to read input range for analog pin %s", analogPin.Name)
-		}
-		if n != 1 {
-			return nil, fmt.Errorf("expected 1 byte, got %#v", bufInputRange)
+		if _, err := analogPin.ControlChip.readAt(bufInputRange, int64(inputRangeAddress), true); err != nil {
+			return nil, fmt.Errorf("failed to read input range for analog pin %s: %w", analogPin.Name, err)
 		}
 		analogPin.info, err = getAnalogInputRange(bufInputRange[0])
 		if err != nil {
@@ -67,12 +64,8 @@ func initializeAnalogPin(pin SPIVariable, g *gpioChip) (*analogPin, error) {
 			outputRangeAddress = analogPin.inputOffset + 79
 		}
 		bufOutputRange := make([]byte, 1)
-		n, err := analogPin.ControlChip.fileHandle.ReadAt(bufOutputRange, int64(outputRangeAddress))
-		if err != nil {
-			return nil, err
-		}
-		if n != 1 {
-			return nil, fmt.Errorf("unable to determine if pin %s is configured for analog write", analogPin.Name)
+		if _, err := analogPin.ControlChip.readAt(bufOutputRange, int64(outputRangeAddress), true); err != nil {
+			return nil, fmt.Errorf("unable to determine if pin %s is configured for analog write: %w", analogPin.Name, err)
 		}
 		analogPin.ControlChip.logger.Debugf("outputRange Value: %d", bufOutputRange)
 		analogPin.info, err = getAnalogOutputRange(bufOutputRange[0], analogPin.Name)
@@ -83,21 +76,31 @@ func initializeAnalogPin(pin SPIVariable, g *gpioChip) (*analogPin, error) {
 	return &analogPin, nil
 }
 
+// current returns the pin to use for a request. Pins are shared between callers and never modified, so a pin
+// initialized before the configuration was reloaded is replaced by a newly initialized one,
+// as the pin may have moved or had its range changed.
+func (pin *analogPin) current() (*analogPin, error) {
+	if pin.generation == pin.ControlChip.configGeneration.Load() {
+		return pin, nil
+	}
+	return pin.ControlChip.GetAnalogPin(pin.Name)
+}
+
 func (pin *analogPin) Read(ctx context.Context, extra map[string]interface{}) (board.AnalogValue, error) {
+	pin, err := pin.current()
+	if err != nil {
+		return board.AnalogValue{}, err
+	}
 	if !pin.isAnalogInput() {
 		return board.AnalogValue{}, fmt.Errorf("cannot ReadAnalog, pin %s is not an analog input pin", pin.Name)
 	}
 	pin.ControlChip.logger.Debugf("Reading from %v, length: %v byte(s)", pin.Address, pin.Length/8)
 	b := make([]byte, pin.Length/8)
-	n, err := pin.ControlChip.fileHandle.ReadAt(b, int64(pin.Address))
-	pin.ControlChip.logger.Debugf("Read %#v bytes", b)
-	if n != 2 {
-		return board.AnalogValue{}, fmt.Errorf("expected 2 bytes, got %#v", b)
-	}
-	if err != nil {
+	if _, err := pin.ControlChip.readAt(b, int64(pin.Address), isDirect(extra)); err != nil {
 		return board.AnalogValue{}, err
 	}
-	val := binary.LittleEndian.Uint16(b)
+	// analog inputs are signed, as input ranges such as -10000 to 10000 mV include negative values
+	val := int16(binary.LittleEndian.Uint16(b))
 	// NOTE: we currently assume that the input multiplier, divisor, and offset have not been modified
 	// the min and max values will change if a user modifies these.
 	// step size converts mV -> V and micro Amps -> mA
@@ -111,6 +114,10 @@ func (pin *analogPin) Close(ctx context.Context) error {
 }
 
 func (pin *analogPin) Write(ctx context.Context, value int, extra map[string]interface{}) error {
+	pin, err := pin.current()
+	if err != nil {
+		return err
+	}
 	pin.ControlChip.logger.Debugf("Analog: %#v", pin)
 	if !pin.isAnalogOutput() {
 		return fmt.Errorf("cannot Write to Analog, pin %s is not an analog output pin", pin.Name)
@@ -119,15 +126,16 @@ func (pin *analogPin) Write(ctx context.Context, value int, extra map[string]int
 	// validate the requested value is within the range of the pin.
 	// NOTE: we currently assume that the output multiplier, divisor, and offset have not been modified
 	if value > pin.info.max || value < pin.info.min {
-		return fmt.Errorf("value of %v is not within expected range (%v to %v)", value, pin.info.min, pin.info.max)
+		return fmt.Errorf("%w: %v is not within expected range (%v to %v)", ErrOutOfRange, value, pin.info.min, pin.info.max)
 	}
 
+	// output values are 16 bits, writing more would overwrite the next output
 	buf := new(bytes.Buffer)
-	err := binary.Write(buf, binary.LittleEndian, int32(value))
+	err = binary.Write(buf, binary.LittleEndian, int16(value))
 	if err != nil {
 		return err
 	}
-	return pin.ControlChip.writeValue(int64(pin.Address), buf.Bytes())
+	return pin.ControlChip.writeValue(int64(pin.Address), buf.Bytes(), writeOrigin{api: "Write", name: pin.Name})
 }
 
 // Analog output pins are located at address 0 or 2 + outputOffset.
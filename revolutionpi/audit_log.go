@@ -0,0 +1,260 @@
+//go:build linux
+
+// Package revolutionpi implements the Revolution Pi.
+package revolutionpi
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go.viam.com/rdk/logging"
+)
+
+const (
+	// how many recent entries are kept in memory for getAuditLog.
+	auditRingSize = 1000
+	// defaultAuditLogMaxSizeMB is the size the audit log file is rotated at when audit_log_max_size_mb is not set.
+	defaultAuditLogMaxSizeMB = 10
+	// how many rotated audit log files are kept, as .1 being the newest to .3 the oldest.
+	auditLogBackups = 3
+)
+
+// apis recorded in the audit log for writes that are not made by a board api or command.
+const (
+	auditAPIForce   = "force"
+	auditAPIUnforce = "unforce"
+)
+
+// writeOrigin identifies what made a write of the process image, for the audit log.
+type writeOrigin struct {
+	api  string // the api or command that wrote, such as Set or writeParameter
+	name string // the variable written
+}
+
+// auditEntry records a write of the process image.
+type auditEntry struct {
+	Time    time.Time   `json:"time"`
+	API     string      `json:"api"`
+	Name    string      `json:"name"`
+	Address int64       `json:"address"`
+	Bit     *uint8      `json:"bit,omitempty"`
+	Old     interface{} `json:"old"`
+	New     interface{} `json:"new"`
+	// the written bits are held at their forced values until the force is cleared
+	Forced bool `json:"forced,omitempty"`
+	// why the write was denied, if it was
+	Denied string `json:"denied,omitempty"`
+	// why the write failed, if it was allowed but could not be made
+	Error string `json:"error,omitempty"`
+}
+
+// auditLog keeps recent writes in memory and appends every write to a file as a line of json. The file is rotated
+// once it reaches its maximum size.
+type auditLog struct {
+	path    string // empty if entries are only kept in memory
+	maxSize int64
+	logger  logging.Logger
+
+	mu      sync.Mutex
+	file    *os.File
+	size    int64
+	failing bool // the last write to the file failed, so the next failure is not logged again
+	ring    []auditEntry
+	next    int // where the next entry goes in ring, once it is full
+}
+
+// newAuditLog opens the audit log file at path for appending. An empty path only keeps entries in memory.
+func newAuditLog(path string, maxSizeMB float64, logger logging.Logger) (*auditLog, error) {
+	if maxSizeMB <= 0 {
+		maxSizeMB = defaultAuditLogMaxSizeMB
+	}
+	a := &auditLog{path: path, maxSize: int64(maxSizeMB * (1 << 20)), logger: logger}
+	if path == "" {
+		return a, nil
+	}
+	if err := a.open(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (a *auditLog) open() error {
+	if err := os.MkdirAll(filepath.Dir(a.path), 0o750); err != nil {
+		return fmt.Errorf("failed to create the audit log directory: %w", err)
+	}
+	file, err := os.OpenFile(a.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o640)
+	if err != nil {
+		return fmt.Errorf("failed to open the audit log: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to open the audit log: %w", err)
+	}
+	a.file, a.size = file, info.Size()
+	return nil
+}
+
+// record adds an entry to the audit log. Failures to write the file are logged, since the write it records
+// has already been made.
+func (a *auditLog) record(entry auditEntry) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.ring) < auditRingSize {
+		a.ring = append(a.ring, entry)
+	} else {
+		a.ring[a.next] = entry
+		a.next = (a.next + 1) % auditRingSize
+	}
+	if a.path == "" {
+		return
+	}
+
+	err := a.writeLine(entry)
+	switch {
+	case err != nil && !a.failing:
+		a.logger.Errorf("failed to write to the audit log %s: %v", a.path, err)
+	case err == nil && a.failing:
+		a.logger.Infof("writing to the audit log %s again", a.path)
+	}
+	a.failing = err != nil
+}
+
+// writeLine appends an entry to the file, rotating it first if the entry does not fit. It must be called with mu held.
+func (a *auditLog) writeLine(entry auditEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+	if a.file == nil || a.size+int64(len(line)) > a.maxSize {
+		if err := a.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := a.file.Write(line)
+	a.size += int64(n)
+	return err
+}
+
+// rotate renames the file to .1, shifting older files up and removing the oldest, and opens a new file.
+// If the file could not be opened before, it is opened again without rotating. It must be called with mu held.
+func (a *auditLog) rotate() error {
+	if a.file != nil {
+		if err := a.file.Close(); err != nil {
+			a.logger.Debugf("failed to close the audit log: %v", err)
+		}
+		a.file = nil
+		for i := auditLogBackups - 1; i > 0; i-- {
+			if err := os.Rename(fmt.Sprintf("%s.%d", a.path, i), fmt.Sprintf("%s.%d", a.path, i+1)); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		if err := os.Rename(a.path, a.path+".1"); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return a.open()
+}
+
+// recent returns up to limit of the most recent entries, oldest first. A limit of 0 returns every entry in memory.
+func (a *auditLog) recent(limit int) []interface{} {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	ordered := append(append([]auditEntry{}, a.ring[a.next:]...), a.ring[:a.next]...)
+	if limit > 0 && limit < len(ordered) {
+		ordered = ordered[len(ordered)-limit:]
+	}
+	entries := make([]interface{}, 0, len(ordered))
+	for _, entry := range ordered {
+		e := map[string]interface{}{
+			"time":    entry.Time.Format(time.RFC3339Nano),
+			"api":     entry.API,
+			"name":    entry.Name,
+			"address": entry.Address,
+			"old":     entry.Old,
+			"new":     entry.New,
+		}
+		if entry.Bit != nil {
+			e["bit"] = int(*entry.Bit)
+		}
+		if entry.Forced {
+			e["forced"] = true
+		}
+		if entry.Denied != "" {
+			e["denied"] = entry.Denied
+		}
+		if entry.Error != "" {
+			e["error"] = entry.Error
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+// Close closes the audit log file.
+func (a *auditLog) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	// entries recorded after closing are only kept in memory
+	a.path = ""
+	if a.file == nil {
+		return nil
+	}
+	err := a.file.Close()
+	a.file = nil
+	return err
+}
+
+// auditOld reads the bytes a write is about to replace, or returns nil if the chip has no audit log.
+func (g *gpioChip) auditOld(address int64, length int) []byte {
+	if g.audit == nil {
+		return nil
+	}
+	old := make([]byte, length)
+	if _, err := g.readUnforced(old, address, true); err != nil {
+		g.logger.Debugf("failed to read the value before a write for the audit log: %v", err)
+		return nil
+	}
+	return old
+}
+
+// auditWrite records a write of b at address in the audit log of the chip, if it has one. For a write of a single
+// bit, bit is the position of the bit in the byte at address, and old and b hold the whole byte. err is why the
+// write was denied or failed, if it was. It must be called with writeMu held, so that old is what b replaced.
+func (g *gpioChip) auditWrite(origin writeOrigin, address int64, bit *uint8, old, b []byte, forced bool, err error) {
+	if g.audit == nil {
+		return
+	}
+	entry := auditEntry{
+		Time: time.Now(), API: origin.api, Name: origin.name, Address: address, Bit: bit,
+		Old: auditValue(old, bit), New: auditValue(b, bit), Forced: forced,
+	}
+	switch {
+	case errors.Is(err, ErrWriteDenied), errors.Is(err, ErrInterlocked):
+		entry.Denied = err.Error()
+	case err != nil:
+		entry.Error = err.Error()
+	}
+	g.audit.record(entry)
+}
+
+// auditValue formats written bytes for the audit log: a bool for a single bit, an unsigned integer for up to 4 bytes,
+// and hex otherwise. It returns nil if the bytes are unknown.
+func auditValue(b []byte, bit *uint8) interface{} {
+	switch {
+	case b == nil:
+		return nil
+	case bit != nil:
+		return (b[0]>>*bit)&1 == 1
+	case len(b) <= 4:
+		return readUint(b)
+	default:
+		return hex.EncodeToString(b)
+	}
+}
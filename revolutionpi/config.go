@@ -4,8 +4,12 @@
 package revolutionpi
 
 import (
+	"errors"
+	"fmt"
+
 	"go.viam.com/rdk/resource"
-	"go.viam.com/rdk/utils"
+	rdkutils "go.viam.com/rdk/utils"
+	"go.viam.com/utils"
 )
 
 // Model is the model triplet for the rev-pi board.
@@ -13,6 +17,154 @@ var Model = resource.NewModel("viam", "kunbus", "revolutionpi")
 
 // Config is the config for the rev-pi board.
 type Config struct {
-	resource.TriviallyValidateConfig
-	Attributes utils.AttributeMap `json:"attributes,omitempty"`
+	Attributes rdkutils.AttributeMap `json:"attributes,omitempty"`
+	// DevicePath is the device node of the process image. Defaults to /dev/piControl0.
+	DevicePath string `json:"device_path,omitempty"`
+	// Totalizers lists the counter pins to keep persistent totals for.
+	Totalizers []string `json:"totalizers,omitempty"`
+	// TotalizerStateFile is where totals are saved. Defaults to a file in the module's data directory.
+	TotalizerStateFile string `json:"totalizer_state_file,omitempty"`
+	// VariableTypes declares how variables are decoded and encoded, keyed by variable name.
+	// Variables without a declared type are inferred from the PiCtory configuration.
+	VariableTypes map[string]VariableTypeConfig `json:"variable_types,omitempty"`
+	// DeviceMonitorIntervalSec is how often the device list is checked for lost or returning modules. Defaults to 5 seconds.
+	DeviceMonitorIntervalSec float64 `json:"device_monitor_interval_sec,omitempty"`
+	// RequiredModules lists the modules the board cannot start without. When it is set, other modules are optional
+	// and the board starts in a degraded state if they are missing. When it is not set, every module is required.
+	RequiredModules []RequiredModule `json:"required_modules,omitempty"`
+	// ScanIntervalMs enables the scan loop, which copies the process image at this interval so that
+	// reads are served from memory. When it is not set, every read reads the process image.
+	ScanIntervalMs int `json:"scan_interval_ms,omitempty"`
+	// SafeOutputs are the values written to outputs, keyed by variable name, before IO is stopped by SetPowerMode.
+	SafeOutputs map[string]interface{} `json:"safe_outputs,omitempty"`
+	// ForceTimeoutSec is how long forces last unless the force command sets a shorter timeout. Defaults to 10 minutes.
+	ForceTimeoutSec float64 `json:"force_timeout_sec,omitempty"`
+	// ReadOnly denies every write made through the board.
+	ReadOnly bool `json:"read_only,omitempty"`
+	// WritePolicies restrict writes to variables, keyed by variable name.
+	WritePolicies map[string]WritePolicyConfig `json:"write_policies,omitempty"`
+	// DefaultWritePolicy is the policy of variables without a write policy, either allow or deny. Defaults to allow.
+	DefaultWritePolicy string `json:"default_write_policy,omitempty"`
+	// Interlocks are rules that reject writes while conditions on other variables hold.
+	Interlocks []InterlockConfig `json:"interlocks,omitempty"`
+	// AuditLogFile is where every write made through the board is recorded. Defaults to a file in the module's
+	// data directory.
+	AuditLogFile string `json:"audit_log_file,omitempty"`
+	// AuditLogMaxSizeMB is the size the audit log file is rotated at. Defaults to 10 MB.
+	AuditLogMaxSizeMB float64 `json:"audit_log_max_size_mb,omitempty"`
+	// DebounceMs is how long digital inputs must hold a new value before it is accepted, keyed by variable name.
+	DebounceMs map[string]float64 `json:"debounce_ms,omitempty"`
+}
+
+// Validate validates the Config.
+func (cfg *Config) Validate(path string) ([]string, error) {
+	if err := validateDevicePath(cfg.DevicePath); err != nil {
+		return nil, utils.NewConfigValidationError(path, err)
+	}
+	for name, varType := range cfg.VariableTypes {
+		if err := varType.Validate(); err != nil {
+			return nil, utils.NewConfigValidationError(path, fmt.Errorf("invalid type for variable %s: %w", name, err))
+		}
+	}
+	for _, module := range cfg.RequiredModules {
+		if err := module.Validate(); err != nil {
+			return nil, utils.NewConfigValidationError(path, err)
+		}
+	}
+	for name, policy := range cfg.WritePolicies {
+		if err := policy.Validate(); err != nil {
+			return nil, utils.NewConfigValidationError(path, fmt.Errorf("invalid write policy for variable %s: %w", name, err))
+		}
+	}
+	interlockNames := map[string]bool{}
+	for _, interlock := range cfg.Interlocks {
+		if err := interlock.Validate(); err != nil {
+			return nil, utils.NewConfigValidationError(path, err)
+		}
+		if interlockNames[interlock.Name] {
+			return nil, utils.NewConfigValidationError(path, fmt.Errorf("interlock names must be unique, got %q twice", interlock.Name))
+		}
+		interlockNames[interlock.Name] = true
+	}
+	switch cfg.DefaultWritePolicy {
+	case "", writePolicyAllow, writePolicyDeny:
+	default:
+		return nil, utils.NewConfigValidationError(path,
+			fmt.Errorf("default_write_policy must be %s or %s, got %q", writePolicyAllow, writePolicyDeny, cfg.DefaultWritePolicy))
+	}
+	if cfg.ReadOnly && len(cfg.SafeOutputs) > 0 {
+		return nil, utils.NewConfigValidationError(path, errors.New("safe_outputs cannot be written when read_only is set"))
+	}
+	for name, value := range cfg.SafeOutputs {
+		switch value.(type) {
+		case bool, float64:
+		default:
+			return nil, utils.NewConfigValidationError(path, fmt.Errorf("safe output %s must be a bool or a number, got %v", name, value))
+		}
+		if err := cfg.checkSafeOutputPolicy(name, value); err != nil {
+			return nil, utils.NewConfigValidationError(path, err)
+		}
+	}
+	if cfg.ScanIntervalMs < 0 {
+		return nil, utils.NewConfigValidationError(path, errors.New("scan_interval_ms cannot be negative"))
+	}
+	if cfg.DeviceMonitorIntervalSec < 0 {
+		return nil, utils.NewConfigValidationError(path, errors.New("device_monitor_interval_sec cannot be negative"))
+	}
+	if cfg.AuditLogMaxSizeMB < 0 {
+		return nil, utils.NewConfigValidationError(path, errors.New("audit_log_max_size_mb cannot be negative"))
+	}
+	for name, ms := range cfg.DebounceMs {
+		if ms <= 0 {
+			return nil, utils.NewConfigValidationError(path, fmt.Errorf("debounce_ms of %s must be positive, got %v", name, ms))
+		}
+	}
+	if cfg.ForceTimeoutSec < 0 {
+		return nil, utils.NewConfigValidationError(path, errors.New("force_timeout_sec cannot be negative"))
+	}
+	return []string{}, nil
+}
+
+// checkSafeOutputPolicy checks that the write policies allow a safe output to be written.
+func (cfg *Config) checkSafeOutputPolicy(name string, value interface{}) error {
+	policy, ok := cfg.WritePolicies[name]
+	if !ok {
+		if cfg.DefaultWritePolicy == writePolicyDeny {
+			return fmt.Errorf("safe output %s has no write policy allowing it to be written", name)
+		}
+		return nil
+	}
+	if err := policy.allows(name, value); err != nil {
+		return fmt.Errorf("safe output %s is not allowed by its write policy: %w", name, err)
+	}
+	return nil
+}
+
+// RequiredModule identifies a module by either its position in PiCtory or its serial number.
+type RequiredModule struct {
+	Position *int    `json:"position,omitempty"`
+	Serial   *uint32 `json:"serial,omitempty"`
+}
+
+// Validate checks that exactly one way of identifying the module is set.
+func (m RequiredModule) Validate() error {
+	if (m.Position == nil) == (m.Serial == nil) {
+		return errors.New("a required module must set exactly one of position or serial")
+	}
+	return nil
+}
+
+// matches checks whether the required module is the given device.
+func (m RequiredModule) matches(dev SDeviceInfo) bool {
+	if m.Position != nil {
+		return int(dev.i8uAddress) == *m.Position
+	}
+	return dev.i32uSerialnumber == *m.Serial
+}
+
+func (m RequiredModule) String() string {
+	if m.Position != nil {
+		return fmt.Sprintf("module at position %d", *m.Position)
+	}
+	return fmt.Sprintf("module with serial %d", *m.Serial)
 }
@@ -0,0 +1,225 @@
+//go:build linux
+
+// Package revolutionpi implements the Revolution Pi board GPIO pins.
+package revolutionpi
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"time"
+	"unsafe"
+
+	"go.uber.org/multierr"
+	"go.viam.com/utils"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// PiCtory writes the configuration and then resets the driver, so wait for both before reloading.
+	configReloadDelay = time.Second
+	// how long to wait for inotify events before checking if the watcher was stopped.
+	inotifyPollTimeoutMs = 500
+	// how often the device list is checked for a new layout, when piControl does not report resets.
+	deviceLayoutPollInterval = time.Second
+	// how often the thread waiting for piControl events is signalled until the wait stops.
+	resetWaiterInterruptInterval = 10 * time.Millisecond
+)
+
+// piControlEventReset is the event kbWaitForEvent reports when the piControl driver was reset.
+const piControlEventReset = 1
+
+// moduleLayout is where a module of the device list is in the process image.
+type moduleLayout struct {
+	address                   uint8
+	moduleType                uint16
+	inputOffset, outputOffset uint16
+	inputLength, outputLength uint16
+}
+
+// startConfigWatcher reloads the configuration of the board whenever PiCtory saves a new Start-Config to
+// config.rsc or the piControl driver is reset. Resets are reported by every device node, while config.rsc is only
+// watched when the board uses the default device node, as the PiCtory configuration of other device nodes is unknown.
+func (b *revolutionPiBoard) startConfigWatcher() error {
+	changes := make(chan struct{}, 1)
+	b.resetWaiterDone = make(chan struct{})
+	b.activeBackgroundWorkers.Add(2)
+	utils.ManagedGo(func() { b.waitForResets(changes) }, b.activeBackgroundWorkers.Done)
+	utils.ManagedGo(func() { b.reloadOnChange(changes) }, b.activeBackgroundWorkers.Done)
+
+	configPath := b.controlChip.piCtoryConfigPath
+	if configPath == "" {
+		b.logger.Infof("not watching the PiCtory configuration, as it is unknown for %s", b.controlChip.dev)
+		return nil
+	}
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return err
+	}
+	// watch the directory, as the file may be replaced rather than written in place
+	_, err = unix.InotifyAddWatch(fd, filepath.Dir(configPath), unix.IN_CLOSE_WRITE|unix.IN_MOVED_TO|unix.IN_CREATE)
+	if err != nil {
+		return multierr.Combine(err, unix.Close(fd))
+	}
+	b.activeBackgroundWorkers.Add(1)
+	utils.ManagedGo(func() { b.watchConfigFile(fd, filepath.Base(configPath), changes) }, b.activeBackgroundWorkers.Done)
+	return nil
+}
+
+// watchConfigFile signals changes when the file named configName is written.
+func (b *revolutionPiBoard) watchConfigFile(fd int, configName string, changes chan<- struct{}) {
+	defer func() {
+		if err := unix.Close(fd); err != nil {
+			b.logger.Debugf("failed to close inotify watcher: %v", err)
+		}
+	}()
+	buf := make([]byte, 4096)
+	for {
+		select {
+		case <-b.cancelCtx.Done():
+			return
+		default:
+		}
+		n, err := unix.Poll([]unix.PollFd{{Fd: int32(fd), Events: unix.POLLIN}}, inotifyPollTimeoutMs)
+		if errors.Is(err, unix.EINTR) || n == 0 {
+			continue
+		}
+		if err != nil {
+			b.logger.Warnf("stopped watching the PiCtory configuration: %v", err)
+			return
+		}
+		n, err = unix.Read(fd, buf)
+		if err != nil || n < unix.SizeofInotifyEvent {
+			continue
+		}
+		for offset := 0; offset+unix.SizeofInotifyEvent <= n; {
+			//nolint:gosec
+			event := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameStart := offset + unix.SizeofInotifyEvent
+			name := bytes.TrimRight(buf[nameStart:nameStart+int(event.Len)], "\x00")
+			if string(name) == configName {
+				b.logger.Debugf("%s was written", configName)
+				signalChange(changes)
+			}
+			offset = nameStart + int(event.Len)
+		}
+	}
+}
+
+// waitForResets signals changes whenever piControl reports a reset, whether or not the reset changed the layout of
+// the modules. kbWaitForEvent blocks until an event arrives, so the wait runs on a locked thread that stopResetWaiter
+// signals to interrupt it. If piControl does not report events, the device list is polled for a new layout instead.
+func (b *revolutionPiBoard) waitForResets(changes chan<- struct{}) {
+	defer close(b.resetWaiterDone)
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	b.resetWaiterThread.Store(int32(unix.Gettid()))
+	defer b.resetWaiterThread.Store(0)
+	for b.cancelCtx.Err() == nil {
+		event, err := b.controlChip.waitForEvent()
+		switch {
+		case b.cancelCtx.Err() != nil:
+			return
+		case errors.Is(err, unix.EINTR):
+		case err != nil:
+			b.logger.Infof("piControl does not report resets, checking the device list for a new layout instead: %v", err)
+			b.watchDeviceLayout(changes)
+			return
+		case event == piControlEventReset:
+			b.logger.Debug("piControl was reset")
+			signalChange(changes)
+		}
+	}
+}
+
+// stopResetWaiter interrupts the wait for piControl events until it stops. It must be called after the board was
+// cancelled.
+func (b *revolutionPiBoard) stopResetWaiter() {
+	if b.resetWaiterDone == nil {
+		return
+	}
+	for {
+		// the runtime ignores SIGURG outside of preempting goroutines, so it only makes piControl return from the wait
+		if thread := b.resetWaiterThread.Load(); thread != 0 {
+			if err := unix.Tgkill(unix.Getpid(), int(thread), unix.SIGURG); err != nil {
+				b.logger.Debugf("failed to interrupt the wait for piControl events: %v", err)
+			}
+		}
+		select {
+		case <-b.resetWaiterDone:
+			return
+		case <-time.After(resetWaiterInterruptInterval):
+		}
+	}
+}
+
+// watchDeviceLayout signals changes when piControl was reset with a configuration that added, removed or moved
+// modules, for drivers that do not report resets through kbWaitForEvent.
+func (b *revolutionPiBoard) watchDeviceLayout(changes chan<- struct{}) {
+	layout := deviceLayout(b.controlChip.getDevices())
+	ticker := time.NewTicker(deviceLayoutPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-b.cancelCtx.Done():
+			return
+		case <-ticker.C:
+		}
+		devices, err := b.controlChip.getDeviceList()
+		if err != nil {
+			b.logger.Debugf("failed to check the device list for a new layout: %v", err)
+			continue
+		}
+		current := deviceLayout(devices)
+		if !slices.Equal(current, layout) {
+			b.logger.Debug("the device list has a new layout, piControl was reset")
+			layout = current
+			signalChange(changes)
+		}
+	}
+}
+
+// deviceLayout returns where each module of the device list is in the process image. Modules that are lost keep
+// their layout, so only a new configuration changes it.
+func deviceLayout(devices []SDeviceInfo) []moduleLayout {
+	layout := make([]moduleLayout, 0, len(devices))
+	for _, dev := range devices {
+		layout = append(layout, moduleLayout{
+			address: dev.i8uAddress, moduleType: dev.i16uModuleType &^ piControlNotConnected,
+			inputOffset: dev.i16uInputOffset, outputOffset: dev.i16uOutputOffset,
+			inputLength: dev.i16uInputLength, outputLength: dev.i16uOutputLength,
+		})
+	}
+	return layout
+}
+
+// reloadOnChange reloads the configuration once changes have settled.
+func (b *revolutionPiBoard) reloadOnChange(changes <-chan struct{}) {
+	timer := time.NewTimer(configReloadDelay)
+	timer.Stop()
+	defer timer.Stop()
+	for {
+		select {
+		case <-b.cancelCtx.Done():
+			return
+		case <-changes:
+			timer.Reset(configReloadDelay)
+		case <-timer.C:
+			b.logger.Info("PiCtory configuration changed, reloading")
+			if err := b.controlChip.reloadConfiguration(); err != nil {
+				b.logger.Errorf("failed to reload the PiCtory configuration: %v", err)
+				continue
+			}
+			b.deviceMonitor.poll()
+		}
+	}
+}
+
+func signalChange(changes chan<- struct{}) {
+	select {
+	case changes <- struct{}{}:
+	default:
+	}
+}
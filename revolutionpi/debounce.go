@@ -0,0 +1,163 @@
+//go:build linux
+
+// Package revolutionpi implements the Revolution Pi.
+package revolutionpi
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.viam.com/rdk/logging"
+)
+
+const (
+	// debouncedKey can be set in the extra of Get to read the debounced value of an input.
+	debouncedKey = "debounced"
+	// the debounce worker samples inputs at a quarter of the shortest debounce time, but no faster than this.
+	minDebounceSampleInterval = time.Millisecond
+)
+
+// debouncedInput filters the bounces of a digital input. A change of the raw value is only accepted once the input
+// has held the new value for the debounce time.
+type debouncedInput struct {
+	pin      *gpioPin
+	debounce time.Duration
+
+	raw      bool
+	rawSince time.Time // when the raw value last changed
+	value    bool
+	// when the raw value changed for the last accepted rising and falling edges
+	lastRising, lastFalling   time.Time
+	risingEdges, fallingEdges uint64
+}
+
+// debounceManager samples the debounced inputs of the board.
+type debounceManager struct {
+	logger   logging.Logger
+	interval time.Duration
+	mu       sync.Mutex
+	inputs   map[string]*debouncedInput
+}
+
+// newDebounceManager debounces the given inputs, keyed by name, with their debounce times in milliseconds.
+// The debounced value of every input starts as its current value.
+func newDebounceManager(g *gpioChip, debounceMs map[string]float64, logger logging.Logger) (*debounceManager, error) {
+	m := &debounceManager{logger: logger, inputs: map[string]*debouncedInput{}}
+	now := time.Now()
+	for name, ms := range debounceMs {
+		pin, err := g.GetGPIOPin(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to set up debouncing for pin %s: %w", name, err)
+		}
+		value, err := pin.Get(context.Background(), nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to set up debouncing for pin %s: %w", name, err)
+		}
+		debounce := time.Duration(ms * float64(time.Millisecond))
+		m.inputs[name] = &debouncedInput{pin: pin, debounce: debounce, raw: value, rawSince: now, value: value}
+		if m.interval == 0 || debounce/4 < m.interval {
+			m.interval = debounce / 4
+		}
+	}
+	m.interval = max(m.interval, minDebounceSampleInterval)
+	return m, nil
+}
+
+// run samples the inputs until ctx is cancelled.
+func (m *debounceManager) run(ctx context.Context) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			m.sample(ctx, now)
+		}
+	}
+}
+
+func (m *debounceManager) sample(ctx context.Context, now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for name, input := range m.inputs {
+		raw, err := input.pin.Get(ctx, nil)
+		if err != nil {
+			m.logger.Errorf("failed to read debounced input %s: %v", name, err)
+			continue
+		}
+		if raw != input.raw {
+			input.raw, input.rawSince = raw, now
+		}
+		if input.raw == input.value || now.Sub(input.rawSince) < input.debounce {
+			continue
+		}
+		input.value = input.raw
+		if input.value {
+			input.lastRising = input.rawSince
+			input.risingEdges++
+		} else {
+			input.lastFalling = input.rawSince
+			input.fallingEdges++
+		}
+	}
+}
+
+// value returns the debounced value of an input.
+func (m *debounceManager) value(name string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	input, ok := m.inputs[name]
+	if !ok {
+		return false, fmt.Errorf("pin %s is not configured for debouncing", name)
+	}
+	return input.value, nil
+}
+
+// edges returns the debounced value and the last edges of the given inputs, or of every input if names is empty.
+func (m *debounceManager) edges(names []string) (map[string]interface{}, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(names) == 0 {
+		for name := range m.inputs {
+			names = append(names, name)
+		}
+	}
+	resp := make(map[string]interface{}, len(names))
+	for _, name := range names {
+		input, ok := m.inputs[name]
+		if !ok {
+			return nil, fmt.Errorf("pin %s is not configured for debouncing", name)
+		}
+		resp[name] = map[string]interface{}{
+			"value":         input.value,
+			"raw":           input.raw,
+			"last_rising":   edgeTime(input.lastRising),
+			"last_falling":  edgeTime(input.lastFalling),
+			"rising_edges":  input.risingEdges,
+			"falling_edges": input.fallingEdges,
+		}
+	}
+	return resp, nil
+}
+
+// edgeTime formats the time of an edge, or returns nil if the edge has not happened.
+func edgeTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t.Format(time.RFC3339Nano)
+}
+
+// Get gets the state of the pin. If debounced is set in extra, the debounced value of the input is returned instead.
+func (pin *boardGPIOPin) Get(ctx context.Context, extra map[string]interface{}) (bool, error) {
+	if debounced, _ := extra[debouncedKey].(bool); debounced {
+		if pin.board.debouncer == nil {
+			return false, fmt.Errorf("pin %s is not configured for debouncing", pin.Name)
+		}
+		return pin.board.debouncer.value(pin.Name)
+	}
+	return pin.gpioPin.Get(ctx, extra)
+}
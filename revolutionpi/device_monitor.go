@@ -0,0 +1,142 @@
+//go:build linux
+
+// Package revolutionpi implements the Revolution Pi board GPIO pins.
+package revolutionpi
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"go.viam.com/rdk/logging"
+)
+
+const defaultDeviceMonitorInterval = 5 * time.Second
+
+// moduleStatus is the last known state of a module in the device list.
+type moduleStatus struct {
+	position    uint8
+	moduleType  uint16
+	serial      uint32
+	active      bool
+	moduleState uint8
+	lastChange  time.Time
+}
+
+// deviceMonitor periodically re-reads the device list, tracking modules that are lost or return.
+type deviceMonitor struct {
+	chip     *gpioChip
+	logger   logging.Logger
+	interval time.Duration
+
+	mu      sync.Mutex
+	modules map[uint8]*moduleStatus // keyed by the module's address in the configuration
+	polled  bool
+}
+
+func newDeviceMonitor(chip *gpioChip, interval time.Duration, logger logging.Logger) *deviceMonitor {
+	return &deviceMonitor{chip: chip, logger: logger, interval: interval, modules: map[uint8]*moduleStatus{}}
+}
+
+// run polls the device list until ctx is cancelled.
+func (m *deviceMonitor) run(ctx context.Context) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.poll()
+		}
+	}
+}
+
+// poll reads the device list and logs any module transitions. The cached DIO and AIO device lists
+// are refreshed when a module is lost or returns, so pins on a returning module work again without a restart.
+func (m *deviceMonitor) poll() {
+	devices, err := m.chip.getDeviceList()
+	if err != nil {
+		m.logger.Errorf("failed to monitor devices: %v", err)
+		return
+	}
+	if m.update(devices) {
+		m.chip.setDevices(devices)
+	}
+}
+
+// update records the state of each device, returning whether any module became active or inactive.
+func (m *deviceMonitor) update(devices []SDeviceInfo) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	changed := false
+	for _, dev := range devices {
+		active := dev.i8uActive != 0
+		moduleType := dev.i16uModuleType &^ piControlNotConnected
+		prev, known := m.modules[dev.i8uAddress]
+		if !known {
+			m.modules[dev.i8uAddress] = &moduleStatus{
+				position: dev.i8uAddress, moduleType: moduleType, serial: dev.i32uSerialnumber,
+				active: active, moduleState: dev.i8uModuleState, lastChange: now,
+			}
+			// modules seen on the first poll are the baseline, later ones were connected while running
+			if m.polled {
+				m.logger.Infof("module %s appeared at position %d", getModuleName(moduleType), dev.i8uAddress)
+				changed = true
+			}
+			continue
+		}
+
+		switch {
+		case prev.active && !active:
+			m.logger.Warnf("module %s at position %d is no longer active", getModuleName(moduleType), dev.i8uAddress)
+		case !prev.active && active:
+			m.logger.Infof("module %s at position %d is active again", getModuleName(moduleType), dev.i8uAddress)
+		}
+		if prev.moduleState != dev.i8uModuleState {
+			m.logger.Infof("module %s at position %d changed state from %d to %d",
+				getModuleName(moduleType), dev.i8uAddress, prev.moduleState, dev.i8uModuleState)
+		}
+		if prev.active != active || prev.serial != dev.i32uSerialnumber {
+			changed = true
+		}
+		if prev.active != active || prev.serial != dev.i32uSerialnumber || prev.moduleState != dev.i8uModuleState {
+			prev.lastChange = now
+		}
+		prev.active = active
+		prev.moduleState = dev.i8uModuleState
+		prev.moduleType = moduleType
+		prev.serial = dev.i32uSerialnumber
+	}
+	m.polled = true
+	return changed
+}
+
+// status returns the last known state of every module, ordered by position.
+func (m *deviceMonitor) status() []interface{} {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	modules := make([]*moduleStatus, 0, len(m.modules))
+	for _, module := range m.modules {
+		modules = append(modules, module)
+	}
+	sort.Slice(modules, func(i, j int) bool { return modules[i].position < modules[j].position })
+
+	resp := make([]interface{}, 0, len(modules))
+	for _, module := range modules {
+		// the status is converted to protobuf, which has no 8 bit integers
+		resp = append(resp, map[string]interface{}{
+			"position":     int(module.position),
+			"module":       getModuleName(module.moduleType),
+			"serial":       module.serial,
+			"active":       module.active,
+			"module_state": int(module.moduleState),
+			"last_change":  module.lastChange.Format(time.RFC3339),
+		})
+	}
+	return resp
+}
@@ -12,6 +12,9 @@ import (
 
 const (
 	inputModeOffset = 88
+	// the largest number of pulses we expect between two reads of a counter. If a counter moves backwards by
+	// less than this amount it wrapped around, otherwise we assume it was reset.
+	counterWrapWindow = 1 << 24
 )
 
 // counterPin is the struct used for configuring an interrupt or encoder.
@@ -30,6 +33,10 @@ type counterPin struct {
 	inputOffset      uint16
 	enabled          bool
 	interruptAddress uint16
+	inputModeAddress uint16 // address of the InputMode byte that configures this pin
+	serialNumber     uint32 // serial number of the DIO module the pin belongs to
+	isEncoder        bool
+	generation       uint64 // configuration generation of the chip when the pin was initialized
 }
 
 // diWrapper wraps a digital interrupt pin with the DigitalInterrupt interface.
@@ -40,16 +47,21 @@ type diWrapper struct {
 func initializeDigitalInterrupt(pin SPIVariable, g *gpioChip, isEncoder bool) (*counterPin, error) {
 	di := counterPin{
 		pinName: str32(pin.strVarName), address: pin.i16uAddress,
-		length: pin.i16uLength, bitPosition: pin.i8uBit, controlChip: g,
+		length: pin.i16uLength, bitPosition: pin.i8uBit, controlChip: g, isEncoder: isEncoder,
 	}
 	g.logger.Debugf("setting up digital interrupt pin: %v", di)
-	dio, err := findDevice(di.address, g.dioDevices)
+	dio, err := findDevice(di.address, g.getDIODevices())
 	if err != nil {
-		return &counterPin{}, err
+		kind := "digital interrupt"
+		if isEncoder {
+			kind = "encoder"
+		}
+		return &counterPin{}, g.explainMissingDevice(di.pinName, kind, di.address, err)
 	}
 	// store the input & output offsets of the board for quick reference
 	di.outputOffset = dio.i16uOutputOffset
 	di.inputOffset = dio.i16uInputOffset
+	di.serialNumber = dio.i32uSerialnumber
 
 	var addressInputMode uint16
 
@@ -71,14 +83,12 @@ func initializeDigitalInterrupt(pin SPIVariable, g *gpioChip, isEncoder bool) (*
 		return &counterPin{}, errors.New("pin is not a digital input pin")
 	}
 
+	di.inputModeAddress = di.inputOffset + inputModeOffset + addressInputMode
+
 	b := make([]byte, 1)
 	// read from the input mode addresses to see if the pin is configured for interrupts
-	n, err := di.controlChip.fileHandle.ReadAt(b, int64(di.inputOffset+inputModeOffset+addressInputMode))
-	if err != nil {
-		return &counterPin{}, err
-	}
-	if n != 1 {
-		return &counterPin{}, errors.New("unable to read digital input pin configuration")
+	if _, err := di.controlChip.readAt(b, int64(di.inputModeAddress), true); err != nil {
+		return &counterPin{}, fmt.Errorf("unable to read digital input pin configuration: %w", err)
 	}
 	di.controlChip.logger.Debugf("Current Pin configuration: %#d", b)
 
@@ -96,32 +106,73 @@ func initializeDigitalInterrupt(pin SPIVariable, g *gpioChip, isEncoder bool) (*
 }
 
 func (di *diWrapper) Value(ctx context.Context, extra map[string]interface{}) (int64, error) {
-	val, err := di.pin.Value()
+	val, err := di.pin.readValue(isDirect(extra))
 	if err != nil {
 		return 0, err
 	}
 	return int64(val), nil
 }
 
+// current returns the pin to use for a request. Pins are shared between callers and never modified, so a pin
+// initialized before the configuration was reloaded is replaced by a newly initialized one,
+// as the pin may have moved or had its input mode changed.
+func (di *counterPin) current() (*counterPin, error) {
+	if di.generation == di.controlChip.configGeneration.Load() {
+		return di, nil
+	}
+	return di.controlChip.getCounterPin(di.pinName, di.isEncoder)
+}
+
 // Note: The revolution pi only supports uint32 counters, while the Value API expects int64.
 func (di *counterPin) Value() (uint32, error) {
+	return di.readValue(false)
+}
+
+// readValue reads the counter, from the latest scan of the process image unless direct is set.
+func (di *counterPin) readValue(direct bool) (uint32, error) {
+	di, err := di.current()
+	if err != nil {
+		return 0, err
+	}
 	if !di.enabled {
 		return 0, fmt.Errorf("cannot get digital interrupt value, pin %s is not configured as an interrupt", di.pinName)
 	}
 	di.controlChip.logger.Debugf("Reading from %d, length: 4 byte(s)", di.interruptAddress)
 	b := make([]byte, 4)
-	n, err := di.controlChip.fileHandle.ReadAt(b, int64(di.interruptAddress))
-	if err != nil {
+	if _, err := di.controlChip.readAt(b, int64(di.interruptAddress), direct); err != nil {
 		return 0, err
 	}
-	di.controlChip.logger.Debugf("Read %#v bytes", b)
-	if n != 4 {
-		return 0, fmt.Errorf("expected 4 bytes, got %#v", b)
-	}
 	val := binary.LittleEndian.Uint32(b)
 	return val, nil
 }
 
+// counterDelta returns the number of pulses counted between two reads of a 32 bit hardware counter.
+// Unsigned subtraction handles a counter that wrapped past its maximum value. A counter that was reset,
+// either by piControl restarting or by kbDIOResetCounter, counted every pulse since the reset.
+func counterDelta(prev, cur uint32) (uint32, bool) {
+	delta := cur - prev
+	if cur >= prev || delta < counterWrapWindow {
+		return delta, false
+	}
+	return cur, true
+}
+
+// inputModeName returns a readable description of the InputMode byte of a DIO input.
+func inputModeName(mode byte) string {
+	switch mode {
+	case 0:
+		return "direct"
+	case 1:
+		return "counter, rising edge"
+	case 2:
+		return "counter, falling edge"
+	case 3:
+		return "encoder"
+	default:
+		return "unknown input mode"
+	}
+}
+
 func (di *diWrapper) Name() string {
 	return di.pin.pinName
 }
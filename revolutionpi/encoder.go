@@ -6,18 +6,24 @@ package revolutionpi
 import (
 	"context"
 	"fmt"
-	"io/fs"
-	"os"
-	"path/filepath"
+	"math"
 	"sync/atomic"
 
+	"go.uber.org/multierr"
 	"go.viam.com/rdk/components/encoder"
-	"go.viam.com/rdk/grpc"
 	"go.viam.com/rdk/logging"
 	"go.viam.com/rdk/resource"
 	"go.viam.com/utils"
 )
 
+const (
+	getRawCountKey   = "getRawCount"
+	getZeroOffsetKey = "getZeroOffset"
+	getInputModeKey  = "getInputMode"
+	getDeviceInfoKey = "getDeviceInfo"
+	setPositionKey   = "setPosition"
+)
+
 // revolutionPiEncoder wraps a digital interrupt pin with the Encoder interface.
 type revolutionPiEncoder struct {
 	resource.Named
@@ -31,7 +37,8 @@ var EncoderModel = resource.NewModel("viam", "kunbus", "revolutionpi-encoder")
 
 // EncoderConfig is the config for the rev-pi board encoder.
 type EncoderConfig struct {
-	Name string `json:"pin_name"`
+	Name       string `json:"pin_name"`
+	DevicePath string `json:"device_path,omitempty"`
 }
 
 func init() {
@@ -46,6 +53,21 @@ func (cfg *EncoderConfig) Validate(path string) ([]string, error) {
 	if cfg.Name == "" {
 		return nil, utils.NewConfigValidationFieldRequiredError(path, "pin_name")
 	}
+	if err := validateDevicePath(cfg.DevicePath); err != nil {
+		return nil, utils.NewConfigValidationError(path, err)
+	}
+	// the pin can only be checked when the PiCtory configuration of the device is known and readable. Unknown pins are
+	// left to the encoder, which reports the closest names when it is created.
+	if configPath := piCtoryConfigPathFor(cfg.DevicePath); configPath != "" {
+		if piCtory, err := loadPiCtoryConfig(configPath); err == nil {
+			if variable, ok := piCtory.variables()[cfg.Name]; ok {
+				if kind := variableKind(variable); kind != "counter" && kind != "digital input" {
+					return nil, utils.NewConfigValidationError(path,
+						fmt.Errorf("pin %s (%s) is not a counter or digital input of a RevPi DIO or DI", cfg.Name, describeVariable(variable)))
+				}
+			}
+		}
+	}
 	return []string{}, nil
 }
 
@@ -59,15 +81,7 @@ func newEncoder(
 	if err != nil {
 		return nil, err
 	}
-	devPath := filepath.Clean(filepath.Join("/dev", "piControl0"))
-	fd, err := os.OpenFile(devPath, os.O_RDWR, fs.FileMode(os.O_RDWR))
-	if err != nil {
-		err = fmt.Errorf("open chip %v failed: %w", devPath, err)
-		return nil, err
-	}
-	chip := gpioChip{dev: devPath, logger: logger, fileHandle: fd}
-
-	err = chip.showDeviceList()
+	chip, err := newGpioChip(svcConfig.DevicePath, logger)
 	if err != nil {
 		return nil, err
 	}
@@ -75,12 +89,12 @@ func newEncoder(
 	pin := SPIVariable{strVarName: char32(name)}
 	err = chip.mapNameToAddress(&pin)
 	if err != nil {
-		return nil, err
+		return nil, multierr.Combine(err, chip.Close())
 	}
 
-	enc, err := initializeDigitalInterrupt(pin, &chip, true)
+	enc, err := initializeDigitalInterrupt(pin, chip, true)
 	if err != nil {
-		return nil, err
+		return nil, multierr.Combine(err, chip.Close())
 	}
 
 	return &revolutionPiEncoder{Named: conf.ResourceName().AsNamed(), pin: enc}, nil
@@ -113,8 +127,69 @@ func (enc *revolutionPiEncoder) Properties(ctx context.Context, extra map[string
 	return encoder.Properties{TicksCountSupported: true, AngleDegreesSupported: false}, nil
 }
 
+// DoCommand exposes encoder diagnostics and allows the position to be set to an arbitrary value for homing.
 func (enc *revolutionPiEncoder) DoCommand(ctx context.Context, req map[string]interface{}) (map[string]interface{}, error) {
-	return nil, grpc.UnimplementedError
+	resp := make(map[string]interface{})
+	found := false
+
+	if _, exists := req[getRawCountKey]; exists {
+		found = true
+		pos, err := enc.pin.Value()
+		if err != nil {
+			return nil, err
+		}
+		// the counter is an int32, like the position
+		resp[getRawCountKey] = int32(pos)
+	}
+	if _, exists := req[getZeroOffsetKey]; exists {
+		found = true
+		resp[getZeroOffsetKey] = enc.zeroPos.Load()
+	}
+	if _, exists := req[getInputModeKey]; exists {
+		found = true
+		// read the mode again, as it may have been changed in PiCtory since the encoder was created
+		mode := make([]byte, 1)
+		if _, err := enc.pin.controlChip.readAt(mode, int64(enc.pin.inputModeAddress), true); err != nil {
+			return nil, err
+		}
+		// responses are converted to protobuf, which has no 8 or 16 bit integers
+		resp[getInputModeKey] = map[string]interface{}{
+			"value":   int(mode[0]),
+			"mode":    inputModeName(mode[0]),
+			"address": int(enc.pin.inputModeAddress),
+		}
+	}
+	if _, exists := req[getDeviceInfoKey]; exists {
+		found = true
+		resp[getDeviceInfoKey] = map[string]interface{}{
+			"serial_number":   enc.pin.serialNumber,
+			"pin_address":     int(enc.pin.address),
+			"counter_address": int(enc.pin.interruptAddress),
+		}
+	}
+	if posMessage, exists := req[setPositionKey]; exists {
+		found = true
+		// values from DoCommand are decoded from json, so numbers arrive as float64
+		target, ok := posMessage.(float64)
+		if !ok {
+			return nil, fmt.Errorf("error performing %s: expected number got %v", setPositionKey, posMessage)
+		}
+		if target > math.MaxInt32 || target < math.MinInt32 {
+			return nil, fmt.Errorf("error performing %s: position %v does not fit in an int32 counter: %w", setPositionKey, target, ErrOutOfRange)
+		}
+		pos, err := enc.pin.Value()
+		if err != nil {
+			return nil, err
+		}
+		// Position reports the raw count minus the zero offset, so shift the offset to land on the target
+		enc.zeroPos.Store(int32(pos) - int32(target))
+		resp[setPositionKey] = int32(target)
+	}
+
+	if !found {
+		return nil, fmt.Errorf("no valid commands found, got %#v", req)
+	}
+	return resp, nil
 }
 
 func (enc *revolutionPiEncoder) Close(ctx context.Context) error {
@@ -0,0 +1,70 @@
+//go:build linux
+
+// Package revolutionpi implements the Revolution Pi.
+package revolutionpi
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+// Errors returned by the module. Errors only keep their message across the gRPC boundary, so clients
+// can check for these by looking for the message of the error, such as "variable not found".
+var (
+	// ErrVariableNotFound is returned when a variable is not defined in the PiCtory configuration.
+	ErrVariableNotFound = errors.New("variable not found")
+	// ErrNotPWMPin is returned when a PWM api is used on a pin that is not a DIO output configured for PWM.
+	ErrNotPWMPin = errors.New("not a PWM pin")
+	// ErrModuleNotConfigured is returned when a connected module is missing from the PiCtory configuration.
+	ErrModuleNotConfigured = errors.New("module not configured")
+	// ErrOutOfRange is returned when a value does not fit the range of a pin or variable.
+	ErrOutOfRange = errors.New("value out of range")
+	// ErrBoardClosed is returned when a pin is used after its board was closed.
+	ErrBoardClosed = errors.New("board closed")
+	// ErrWriteDenied is returned when the board is read only or a write policy does not allow a write.
+	ErrWriteDenied = errors.New("write denied")
+	// ErrInterlocked is returned when a write violates an interlock rule.
+	ErrInterlocked = errors.New("interlocked")
+)
+
+// the size of the buffer piControl copies its last message into.
+const piControlMessageLength = 256
+
+// piControlError is an ioctl that failed, along with the last message of the piControl driver explaining why.
+type piControlError struct {
+	errno   syscall.Errno
+	message string
+}
+
+func (e *piControlError) Error() string {
+	if e.message == "" {
+		return e.errno.Error()
+	}
+	return fmt.Sprintf("%v (piControl: %s)", e.errno, e.message)
+}
+
+func (e *piControlError) Unwrap() error {
+	return e.errno
+}
+
+// lastMessage returns the last error message of the piControl driver, or an empty string if there is none.
+func (g *gpioChip) lastMessage() string {
+	var buf [piControlMessageLength]byte
+	//nolint:gosec
+	// the raw ioctl is used, as a failure to read the message must not try to read the message again
+	_, _, errno, err := g.rawIoCtl(uintptr(kbGetLastMessage), unsafe.Pointer(&buf))
+	if err == nil && errno != 0 {
+		err = errno
+	}
+	if err != nil {
+		g.logger.Debugf("failed to get the last piControl message: %v", err)
+		return ""
+	}
+	if end := bytes.IndexByte(buf[:], 0); end >= 0 {
+		return string(bytes.TrimSpace(buf[:end]))
+	}
+	return string(bytes.TrimSpace(buf[:]))
+}
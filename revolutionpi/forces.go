@@ -0,0 +1,463 @@
+//go:build linux
+
+// Package revolutionpi implements the Revolution Pi.
+package revolutionpi
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+const (
+	// defaultForceTimeout is how long forces last when the config does not set force_timeout_sec.
+	defaultForceTimeout = 10 * time.Minute
+	// how often expired forces are cleared.
+	forceExpiryInterval = time.Second
+	// how often the active forces are logged, so they are not forgotten.
+	forceReminderInterval = time.Minute
+)
+
+// forceTable holds the forces of a process image, keyed by variable name.
+type forceTable struct {
+	mu     sync.RWMutex
+	forces map[string]*force
+}
+
+// the force tables of the process images, keyed by device path. Chips opened on the same process image, such as
+// those of the board and its sensors, share a table so that every chip applies the forces set through the board.
+var (
+	forceTablesMu sync.Mutex
+	forceTables   = map[string]*forceTable{}
+)
+
+// forceTableFor returns the force table of the process image at devPath.
+func forceTableFor(devPath string) *forceTable {
+	forceTablesMu.Lock()
+	defer forceTablesMu.Unlock()
+	table, ok := forceTables[devPath]
+	if !ok {
+		table = &forceTable{}
+		forceTables[devPath] = table
+	}
+	return table
+}
+
+// force holds a variable at a fixed value. Reads of a forced variable return the forced value, and writes to a
+// forced output are recorded but not applied until the force is cleared.
+type force struct {
+	name    string
+	value   interface{}
+	pin     SPIVariable
+	address uint16
+	output  bool
+	mask    []byte // the bits of the variable in the bytes starting at address
+	bits    []byte // the forced value, masked
+	// the last value written to a forced output, which is written to the output when the force is cleared
+	restore  []byte
+	forcedAt time.Time
+	expires  time.Time
+}
+
+// merge replaces the bits of the force in buf, which starts at address, with values.
+func (f *force) merge(buf []byte, address int64, values []byte) {
+	for i := range f.mask {
+		pos := int64(f.address) + int64(i) - address
+		if pos < 0 || pos >= int64(len(buf)) {
+			continue
+		}
+		buf[pos] = buf[pos]&^f.mask[i] | values[i]&f.mask[i]
+	}
+}
+
+// capture records the bits of the force in buf, written at address, as the value to restore.
+func (f *force) capture(buf []byte, address int64) {
+	for i := range f.mask {
+		pos := int64(f.address) + int64(i) - address
+		if pos < 0 || pos >= int64(len(buf)) {
+			continue
+		}
+		f.restore[i] = f.restore[i]&^f.mask[i] | buf[pos]&f.mask[i]
+	}
+}
+
+// forceVariable forces an input or output to value until timeout elapses or the force is cleared. Forcing a
+// variable that is already forced replaces the force.
+func (g *gpioChip) forceVariable(name string, value interface{}, timeout time.Duration) error {
+	pin := SPIVariable{strVarName: char32(name)}
+	if err := g.mapNameToAddress(&pin); err != nil {
+		return err
+	}
+	t, err := g.typeOf(pin)
+	if err != nil {
+		return fmt.Errorf("failed to force %s: %w", name, err)
+	}
+	output, ok := g.sectionOf(pin.i16uAddress)
+	if !ok {
+		return fmt.Errorf("failed to force %s: only inputs and outputs can be forced", name)
+	}
+
+	g.writeMu.Lock()
+	defer g.writeMu.Unlock()
+	current := make([]byte, t.size)
+	if _, err := g.readUnforced(current, int64(pin.i16uAddress), true); err != nil {
+		return fmt.Errorf("failed to force %s: %w", name, err)
+	}
+	f := &force{name: name, value: value, pin: pin, address: pin.i16uAddress, output: output, forcedAt: time.Now()}
+	f.expires = f.forcedAt.Add(timeout)
+	if pin.i16uLength == 1 {
+		high, ok := value.(bool)
+		if !ok {
+			return fmt.Errorf("failed to force %s: expected bool, got %v", name, value)
+		}
+		f.mask = []byte{1 << pin.i8uBit}
+		f.bits = []byte{0}
+		if high {
+			f.bits[0] = f.mask[0]
+		}
+	} else {
+		f.bits, err = encodeValue(t, value, current)
+		if err != nil {
+			return fmt.Errorf("failed to force %s: %w", name, err)
+		}
+		f.mask = make([]byte, len(f.bits))
+		for i := range f.mask {
+			f.mask[i] = 0xff
+		}
+	}
+
+	if f.output {
+		if err := g.checkWrite(int64(f.address), f.bits, f.mask); err != nil {
+			return fmt.Errorf("failed to force %s: %w", name, err)
+		}
+	}
+
+	g.forces.mu.Lock()
+	if g.forces.forces == nil {
+		g.forces.forces = map[string]*force{}
+	}
+	if f.output {
+		if previous, ok := g.forces.forces[name]; ok {
+			// the output currently holds the previous forced value, not the one to restore
+			f.restore = previous.restore
+		} else {
+			f.restore = current
+		}
+	}
+	g.forces.forces[name] = f
+	g.forces.mu.Unlock()
+
+	if f.output {
+		if err := g.writeForceBits(pin, f.bits, f.mask, auditAPIForce); err != nil {
+			g.forces.mu.Lock()
+			delete(g.forces.forces, name)
+			g.forces.mu.Unlock()
+			return fmt.Errorf("failed to force %s: %w", name, err)
+		}
+	}
+	g.logger.Warnf("FORCED %s to %v until %s, reads and writes of %s no longer reflect the process image",
+		name, value, f.expires.Format(time.RFC3339), name)
+	return nil
+}
+
+// writeForceBits writes the masked bits of a forced variable to the process image, bypassing the forces, and records
+// the write in the audit log as made by api. It must be called with writeMu held.
+func (g *gpioChip) writeForceBits(pin SPIVariable, bits, mask []byte, api string) error {
+	origin := writeOrigin{api: api, name: str32(pin.strVarName)}
+	address := int64(pin.i16uAddress)
+	if pin.i16uLength == 1 {
+		old := g.auditOld(address, 1)
+		command := SPIValue{i16uAddress: pin.i16uAddress, i8uBit: pin.i8uBit, i8uValue: bits[0] >> pin.i8uBit & 1}
+		//nolint:gosec
+		if err := g.ioCtl(uintptr(kbSetValue), unsafe.Pointer(&command)); err != nil {
+			err = fmt.Errorf("failed to set bit %d at address %d: %w", pin.i8uBit, pin.i16uAddress, err)
+			g.auditWrite(origin, address, &pin.i8uBit, old, bits, false, err)
+			return err
+		}
+		g.auditWrite(origin, address, &pin.i8uBit, old, bits, false, nil)
+		return nil
+	}
+	buf := make([]byte, len(bits))
+	if _, err := g.readUnforced(buf, address, true); err != nil {
+		g.auditWrite(origin, address, nil, nil, bits, false, err)
+		return err
+	}
+	old := append([]byte(nil), buf...)
+	for i := range buf {
+		buf[i] = buf[i]&^mask[i] | bits[i]&mask[i]
+	}
+	if err := g.writeImage(address, buf); err != nil {
+		g.auditWrite(origin, address, nil, old, buf, false, err)
+		return err
+	}
+	g.auditWrite(origin, address, nil, old, buf, false, nil)
+	return nil
+}
+
+// clearForces clears the forces on the given variables, or every force if names is empty, writing the last value
+// written to each forced output. It returns the names of the cleared forces.
+func (g *gpioChip) clearForces(names []string, reason string) ([]string, error) {
+	g.forces.mu.Lock()
+	var cleared []*force
+	if len(names) == 0 {
+		for _, f := range g.forces.forces {
+			cleared = append(cleared, f)
+		}
+		g.forces.forces = nil
+	} else {
+		for _, name := range names {
+			f, ok := g.forces.forces[name]
+			if !ok {
+				g.forces.mu.Unlock()
+				return nil, fmt.Errorf("%s is not forced", name)
+			}
+			cleared = append(cleared, f)
+		}
+		for _, f := range cleared {
+			delete(g.forces.forces, f.name)
+		}
+	}
+	g.forces.mu.Unlock()
+	return g.releaseForces(cleared, reason)
+}
+
+// expireForces clears the forces that expired before now.
+func (g *gpioChip) expireForces(now time.Time) {
+	g.forces.mu.Lock()
+	var expired []*force
+	for name, f := range g.forces.forces {
+		if !now.Before(f.expires) {
+			expired = append(expired, f)
+			delete(g.forces.forces, name)
+		}
+	}
+	g.forces.mu.Unlock()
+	if _, err := g.releaseForces(expired, "expired"); err != nil {
+		g.logger.Errorf("failed to restore expired forced outputs: %v", err)
+	}
+}
+
+// dropForces clears every force after piControl loaded a new configuration. Outputs whose variable kept its address
+// are restored like any cleared force. Restoring an output whose variable moved or was removed could write another
+// variable, so it keeps its forced value, which is logged and recorded in the audit log.
+func (g *gpioChip) dropForces(reason string) {
+	g.forces.mu.Lock()
+	dropped := make([]*force, 0, len(g.forces.forces))
+	for _, f := range g.forces.forces {
+		dropped = append(dropped, f)
+	}
+	g.forces.forces = nil
+	g.forces.mu.Unlock()
+
+	sort.Slice(dropped, func(i, j int) bool { return dropped[i].name < dropped[j].name })
+	names := make([]string, 0, len(dropped))
+	for _, f := range dropped {
+		names = append(names, f.name)
+		if !f.output {
+			continue
+		}
+		pin := SPIVariable{strVarName: char32(f.name)}
+		if err := g.mapNameToAddress(&pin); err != nil || pin != f.pin {
+			g.leaveForced(f)
+			continue
+		}
+		if err := g.restoreForce(f); err != nil {
+			g.logger.Errorf("failed to restore %s: %v", f.name, err)
+		}
+	}
+	if len(names) > 0 {
+		g.logger.Warnf("cleared the forces on %s: %s", strings.Join(names, ", "), reason)
+	}
+}
+
+// leaveForced records that the output of a cleared force keeps its forced value, as its variable was moved or removed.
+func (g *gpioChip) leaveForced(f *force) {
+	g.writeMu.Lock()
+	defer g.writeMu.Unlock()
+	address := int64(f.address)
+	var bit *uint8
+	if f.pin.i16uLength == 1 {
+		bit = &f.pin.i8uBit
+	}
+	err := fmt.Errorf("%s was moved or removed by the new configuration, its forced value %v was left at address %d",
+		f.name, f.value, address)
+	g.auditWrite(writeOrigin{api: auditAPIUnforce, name: f.name}, address, bit, g.auditOld(address, len(f.restore)), nil, true, err)
+	g.logger.Warn(err)
+}
+
+// releaseForces writes the value to restore of every cleared output, and logs the cleared forces. Restores are
+// checked against the write policy and the interlocks like any other write, and outputs whose restore is rejected
+// keep their forced value.
+func (g *gpioChip) releaseForces(cleared []*force, reason string) ([]string, error) {
+	sort.Slice(cleared, func(i, j int) bool { return cleared[i].name < cleared[j].name })
+	names := make([]string, 0, len(cleared))
+	var restoreErr error
+	for _, f := range cleared {
+		names = append(names, f.name)
+		if !f.output {
+			continue
+		}
+		if err := g.restoreForce(f); err != nil && restoreErr == nil {
+			restoreErr = fmt.Errorf("failed to restore %s: %w", f.name, err)
+		}
+	}
+	if len(names) > 0 {
+		g.logger.Warnf("cleared the forces on %s: %s", strings.Join(names, ", "), reason)
+	}
+	return names, restoreErr
+}
+
+// restoreForce writes the value to restore of a cleared output, if the write policy and the interlocks allow it.
+func (g *gpioChip) restoreForce(f *force) error {
+	g.writeMu.Lock()
+	defer g.writeMu.Unlock()
+	address := int64(f.address)
+	if err := g.checkWrite(address, f.restore, f.mask); err != nil {
+		var bit *uint8
+		if f.pin.i16uLength == 1 {
+			bit = &f.pin.i8uBit
+		}
+		g.auditWrite(writeOrigin{api: auditAPIUnforce, name: f.name}, address, bit, g.auditOld(address, len(f.restore)), f.restore, false, err)
+		g.logger.Warnf("%s keeps its forced value %v, as restoring it was rejected: %v", f.name, f.value, err)
+		return err
+	}
+	return g.writeForceBits(f.pin, f.restore, f.mask, auditAPIUnforce)
+}
+
+// listForces returns the active forces, sorted by name.
+func (g *gpioChip) listForces() []interface{} {
+	g.forces.mu.RLock()
+	defer g.forces.mu.RUnlock()
+	names := make([]string, 0, len(g.forces.forces))
+	for name := range g.forces.forces {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	forces := make([]interface{}, 0, len(names))
+	for _, name := range names {
+		f := g.forces.forces[name]
+		section := "input"
+		if f.output {
+			section = "output"
+		}
+		forces = append(forces, map[string]interface{}{
+			"name":       f.name,
+			"value":      f.value,
+			"section":    section,
+			"forced_at":  f.forcedAt.Format(time.RFC3339),
+			"expires_at": f.expires.Format(time.RFC3339),
+		})
+	}
+	return forces
+}
+
+// forcedNames returns the names of the forced variables, sorted.
+func (g *gpioChip) forcedNames() []string {
+	g.forces.mu.RLock()
+	defer g.forces.mu.RUnlock()
+	names := make([]string, 0, len(g.forces.forces))
+	for name := range g.forces.forces {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// applyForces replaces forced bits in buf, read from address, with their forced values.
+func (g *gpioChip) applyForces(buf []byte, address int64) {
+	g.forces.mu.RLock()
+	defer g.forces.mu.RUnlock()
+	for _, f := range g.forces.forces {
+		f.merge(buf, address, f.bits)
+	}
+}
+
+// unforce replaces the bits of forced outputs in buf, read from address, with the last values written to them,
+// so that read-modify-write sequences do not record the forced values as written by the caller.
+func (g *gpioChip) unforce(buf []byte, address int64) {
+	g.forces.mu.RLock()
+	defer g.forces.mu.RUnlock()
+	for _, f := range g.forces.forces {
+		if f.output {
+			f.merge(buf, address, f.restore)
+		}
+	}
+}
+
+// forceWrite records the bits of forced outputs in b, to be written at address, as the values to restore,
+// and returns a copy of b with the forced values in their place.
+func (g *gpioChip) forceWrite(b []byte, address int64) []byte {
+	g.forces.mu.Lock()
+	defer g.forces.mu.Unlock()
+	if len(g.forces.forces) == 0 {
+		return b
+	}
+	forced := append([]byte(nil), b...)
+	for _, f := range g.forces.forces {
+		if f.output {
+			f.capture(b, address)
+			f.merge(forced, address, f.bits)
+		}
+	}
+	return forced
+}
+
+// forcedBit checks whether a bit belongs to a forced output, in which case high is recorded as the value to restore.
+func (g *gpioChip) forcedBit(address uint16, bitPosition uint8, high bool) bool {
+	g.forces.mu.Lock()
+	defer g.forces.mu.Unlock()
+	bit := byte(1) << bitPosition
+	forced := false
+	for _, f := range g.forces.forces {
+		i := int(address) - int(f.address)
+		if !f.output || i < 0 || i >= len(f.mask) || f.mask[i]&bit == 0 {
+			continue
+		}
+		f.restore[i] &^= bit
+		if high {
+			f.restore[i] |= bit
+		}
+		forced = true
+	}
+	return forced
+}
+
+// sectionOf checks whether an address is in the inputs or the outputs of a device, returning true for outputs.
+// It returns false for ok if the address is in neither, such as the memory variables of a module.
+func (g *gpioChip) sectionOf(address uint16) (output, ok bool) {
+	for _, dev := range g.getDevices() {
+		if address >= dev.i16uInputOffset && address < dev.i16uInputOffset+dev.i16uInputLength {
+			return false, true
+		}
+		if address >= dev.i16uOutputOffset && address < dev.i16uOutputOffset+dev.i16uOutputLength {
+			return true, true
+		}
+	}
+	return false, false
+}
+
+// runForces clears expired forces and regularly logs the active ones until ctx is cancelled.
+func (g *gpioChip) runForces(ctx context.Context) {
+	ticker := time.NewTicker(forceExpiryInterval)
+	defer ticker.Stop()
+	lastReminder := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			g.expireForces(now)
+			if now.Sub(lastReminder) < forceReminderInterval {
+				continue
+			}
+			lastReminder = now
+			if names := g.forcedNames(); len(names) > 0 {
+				g.logger.Warnf("FORCED variables are active: %s", strings.Join(names, ", "))
+			}
+		}
+	}
+}
@@ -4,36 +4,197 @@
 package revolutionpi
 
 import (
+	"bytes"
 	"fmt"
-	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
+	"time"
 	"unsafe"
 
 	"go.uber.org/multierr"
 	"go.viam.com/rdk/logging"
-	"golang.org/x/sys/unix"
 )
 
 type gpioChip struct {
 	dev        string
 	logger     logging.Logger
-	fileHandle *os.File
+	fileHandle processImage
+	// held for reading while the handle is in use, and for writing when it is closed, so the handle is never used
+	// after it is closed. Once closed, every read and write returns ErrBoardClosed.
+	handleMu sync.RWMutex
+	closed   bool
+	// the device lists and PiCtory variables are reloaded while the chip is in use, so they are guarded by configMu
+	configMu   sync.RWMutex
+	devices    []SDeviceInfo // every device in the device list, including inactive ones
 	dioDevices []SDeviceInfo
 	aioDevices []SDeviceInfo
+	baseDevice *SDeviceInfo // the RevPi Core or Connect module the other modules are connected to
+	// types declared in the config, keyed by variable name
+	variableTypes map[string]VariableTypeConfig
+	// the PiCtory configuration of the process image, empty if it is unknown
+	piCtoryConfigPath string
+	// variables from the PiCtory configuration, keyed by variable name
+	piCtoryVariables map[string]piCtoryVariable
+	// incremented whenever the configuration is reloaded, so pins know to re-initialize
+	configGeneration atomic.Uint64
+	// copies the process image every cycle when the board is configured with a scan interval, nil otherwise
+	scan *scanLoop
+	// restricts which variables can be written when the board is configured with a write policy, nil otherwise
+	policy *writePolicy
+	// rejects writes that violate an interlock when the board is configured with interlocks, nil otherwise
+	interlocks *interlocks
+	// records every write of the board, nil for chips that are not used by the board
+	audit *auditLog
+	// initialized pins keyed by name, so lookups on every api request do not re-read the pin configuration.
+	// The cache is cleared whenever the device list or the configuration changes.
+	pinsMu      sync.Mutex
+	gpioPins    map[string]*gpioPin
+	analogPins  map[string]*analogPin
+	counterPins map[string]*counterPin
+	// serializes writes to the process image, so read-modify-write sequences are not interleaved with other writes
+	writeMu sync.Mutex
+	// variables forced to a fixed value, shared with other chips on the same process image.
+	// Every read and write of the chip applies them.
+	forces *forceTable
 }
 
+// newGpioChip opens the piControl device at devPath and validates the list of devices connected to the rev pi.
+// An empty devPath uses the default device node.
+func newGpioChip(devPath string, logger logging.Logger) (*gpioChip, error) {
+	chip, devices, err := openGpioChip(devPath, logger)
+	if err != nil {
+		return nil, err
+	}
+	var deviceErrs error
+	for i, dev := range devices {
+		deviceErrs = multierr.Combine(deviceErrs, dev.configurationError(i))
+	}
+	if deviceErrs != nil {
+		return nil, multierr.Combine(deviceErrs, chip.Close())
+	}
+	return chip, nil
+}
+
+// openGpioChip opens the piControl device and reads the list of devices without validating it,
+// so the caller can decide which devices are required.
+func openGpioChip(devPath string, logger logging.Logger) (*gpioChip, []SDeviceInfo, error) {
+	device, err := openPiControlDevice(devPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	return initGpioChip(device.Name(), device, logger)
+}
+
+// initGpioChip reads the list of devices and the PiCtory variables for an opened process image.
+func initGpioChip(devPath string, image processImage, logger logging.Logger) (*gpioChip, []SDeviceInfo, error) {
+	chip := gpioChip{
+		dev: devPath, logger: logger, fileHandle: image, variableTypes: map[string]VariableTypeConfig{},
+		forces: forceTableFor(devPath), piCtoryConfigPath: piCtoryConfigPathFor(devPath),
+	}
+
+	devices, err := chip.getDeviceList()
+	if err != nil {
+		return nil, nil, multierr.Combine(err, chip.Close())
+	}
+	chip.setDevices(devices)
+
+	chip.loadPiCtoryVariables()
+	return &chip, devices, nil
+}
+
+// loadPiCtoryVariables loads the variables from the PiCtory configuration. The configuration is only used to describe
+// variables, so the chip is usable without it.
+func (g *gpioChip) loadPiCtoryVariables() {
+	var variables map[string]piCtoryVariable
+	if g.piCtoryConfigPath == "" {
+		g.logger.Debugf("the PiCtory configuration of %s is unknown, variable types will be inferred from their length", g.dev)
+	} else if piCtory, err := loadPiCtoryConfig(g.piCtoryConfigPath); err != nil {
+		g.logger.Warnf("unable to load the PiCtory configuration, variable types will be inferred from their length: %v", err)
+	} else {
+		variables = piCtory.variables()
+	}
+	g.configMu.Lock()
+	defer g.configMu.Unlock()
+	g.piCtoryVariables = variables
+}
+
+// getPiCtoryVariable returns a variable from the PiCtory configuration.
+func (g *gpioChip) getPiCtoryVariable(name string) (piCtoryVariable, bool) {
+	g.configMu.RLock()
+	defer g.configMu.RUnlock()
+	variable, ok := g.piCtoryVariables[name]
+	return variable, ok
+}
+
+// getPiCtoryVariables returns every variable from the PiCtory configuration. The map is replaced rather than
+// modified when the configuration is reloaded, so it can be used without holding the lock.
+func (g *gpioChip) getPiCtoryVariables() map[string]piCtoryVariable {
+	g.configMu.RLock()
+	defer g.configMu.RUnlock()
+	return g.piCtoryVariables
+}
+
+// reloadConfiguration re-reads the PiCtory configuration and the device list after piControl loaded a new
+// configuration. Pins created before the reload re-initialize themselves the next time they are used.
+func (g *gpioChip) reloadConfiguration() error {
+	g.loadPiCtoryVariables()
+	devices, err := g.getDeviceList()
+	if err != nil {
+		return err
+	}
+	g.setDevices(devices)
+	g.configGeneration.Add(1)
+	g.clearPins()
+	g.dropForces("the PiCtory configuration changed")
+	return nil
+}
+
+// clearPins clears the cached pins, so they are initialized again on their next lookup.
+func (g *gpioChip) clearPins() {
+	g.pinsMu.Lock()
+	defer g.pinsMu.Unlock()
+	g.gpioPins = nil
+	g.analogPins = nil
+	g.counterPins = nil
+}
+
+// GetGPIOPin returns the GPIO pin with the given name, initializing it on the first lookup.
 func (g *gpioChip) GetGPIOPin(pinName string) (*gpioPin, error) {
+	g.pinsMu.Lock()
+	defer g.pinsMu.Unlock()
+	if pin, ok := g.gpioPins[pinName]; ok {
+		return pin, nil
+	}
+	pin, err := g.initGPIOPin(pinName)
+	if err != nil {
+		return nil, err
+	}
+	if g.gpioPins == nil {
+		g.gpioPins = map[string]*gpioPin{}
+	}
+	g.gpioPins[pinName] = pin
+	return pin, nil
+}
+
+func (g *gpioChip) initGPIOPin(pinName string) (*gpioPin, error) {
+	generation := g.configGeneration.Load()
 	pin := SPIVariable{strVarName: char32(pinName)}
 	err := g.mapNameToAddress(&pin)
 	if err != nil {
 		return nil, err
 	}
 	g.logger.Debugf("Found GPIO pin: %#v", pin)
-	gpioPin := gpioPin{Name: str32(pin.strVarName), Address: pin.i16uAddress, BitPosition: pin.i8uBit, Length: pin.i16uLength, ControlChip: g}
-	dio, err := findDevice(gpioPin.Address, g.dioDevices)
+	gpioPin := gpioPin{
+		Name: str32(pin.strVarName), Address: pin.i16uAddress, BitPosition: pin.i8uBit, Length: pin.i16uLength,
+		ControlChip: g, generation: generation,
+	}
+	dio, err := findDevice(gpioPin.Address, g.getDIODevices())
 	if err != nil {
 		gpioPin.ControlChip.logger.Debug("pin is not from a supported GPIO board")
-		return nil, err
+		return nil, g.explainMissingDevice(gpioPin.Name, "GPIO pin", gpioPin.Address, err)
 	}
 
 	// store the input & output offsets of the board for quick reference
@@ -47,7 +208,26 @@ func (g *gpioChip) GetGPIOPin(pinName string) (*gpioPin, error) {
 	return &gpioPin, nil
 }
 
+// GetAnalogPin returns the analog pin with the given name, initializing it on the first lookup.
 func (g *gpioChip) GetAnalogPin(pinName string) (*analogPin, error) {
+	g.pinsMu.Lock()
+	defer g.pinsMu.Unlock()
+	if pin, ok := g.analogPins[pinName]; ok {
+		return pin, nil
+	}
+	pin, err := g.initAnalogPin(pinName)
+	if err != nil {
+		return nil, err
+	}
+	if g.analogPins == nil {
+		g.analogPins = map[string]*analogPin{}
+	}
+	g.analogPins[pinName] = pin
+	return pin, nil
+}
+
+func (g *gpioChip) initAnalogPin(pinName string) (*analogPin, error) {
+	generation := g.configGeneration.Load()
 	pin := SPIVariable{strVarName: char32(pinName)}
 	err := g.mapNameToAddress(&pin)
 	if err != nil {
@@ -55,88 +235,406 @@ func (g *gpioChip) GetAnalogPin(pinName string) (*analogPin, error) {
 	}
 	g.logger.Debugf("Found Analog pin: %#v", pin)
 
-	return initializeAnalogPin(pin, g)
+	analogPin, err := initializeAnalogPin(pin, g)
+	if err != nil {
+		return nil, err
+	}
+	analogPin.generation = generation
+	return analogPin, nil
 }
 
 func (g *gpioChip) GetDigitalInterrupt(pinName string) (*counterPin, error) {
+	return g.getCounterPin(pinName, false)
+}
+
+// getCounterPin returns a DIO input configured as a counter, or as an encoder if isEncoder is set,
+// initializing it on the first lookup.
+func (g *gpioChip) getCounterPin(pinName string, isEncoder bool) (*counterPin, error) {
+	g.pinsMu.Lock()
+	defer g.pinsMu.Unlock()
+	if pin, ok := g.counterPins[pinName]; ok && pin.isEncoder == isEncoder {
+		return pin, nil
+	}
+	pin, err := g.initCounterPin(pinName, isEncoder)
+	if err != nil {
+		return nil, err
+	}
+	if g.counterPins == nil {
+		g.counterPins = map[string]*counterPin{}
+	}
+	g.counterPins[pinName] = pin
+	return pin, nil
+}
+
+func (g *gpioChip) initCounterPin(pinName string, isEncoder bool) (*counterPin, error) {
+	generation := g.configGeneration.Load()
 	pin := SPIVariable{strVarName: char32(pinName)}
 	err := g.mapNameToAddress(&pin)
 	if err != nil {
 		return nil, err
 	}
 
-	return initializeDigitalInterrupt(pin, g, false)
+	counter, err := initializeDigitalInterrupt(pin, g, isEncoder)
+	if err != nil {
+		return nil, err
+	}
+	counter.generation = generation
+	return counter, nil
 }
 
 func (g *gpioChip) mapNameToAddress(pin *SPIVariable) error {
 	g.logger.Debugf("Looking for address of %#v", pin)
 	//nolint:gosec
-	err := g.ioCtl(uintptr(kbFindVariable), unsafe.Pointer(pin))
-	if err != 0 {
-		e := fmt.Errorf("failed to get pin address info %v failed: %w", g.dev, err)
-		return e
+	if err := g.ioCtl(uintptr(kbFindVariable), unsafe.Pointer(pin)); err != nil {
+		return variableNotFoundError(str32(pin.strVarName), g.getPiCtoryVariables(), err)
 	}
 	g.logger.Debugf("Found address of %#v", pin)
 	return nil
 }
 
-// showDeviceList reads the list of devices from the rev pi and validates the configuration is correct.
-func (g *gpioChip) showDeviceList() error {
+// getDeviceList reads the list of devices from the rev pi.
+func (g *gpioChip) getDeviceList() ([]SDeviceInfo, error) {
 	var deviceInfoList [255]SDeviceInfo
-	g.dioDevices = []SDeviceInfo{}
-	g.aioDevices = []SDeviceInfo{}
 	//nolint:gosec
 	cnt, _, err := g.ioCtlReturns(uintptr(kbGetDeviceInfoList), unsafe.Pointer(&deviceInfoList))
-	if err != 0 {
-		e := fmt.Errorf("failed to retrieve device info list: %d", -int(cnt))
-		return e
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve device info list from %v: %w", g.dev, err)
 	}
+	return append([]SDeviceInfo{}, deviceInfoList[:cnt]...), nil
+}
 
-	var deviceErrs error
-	for i := 0; i < int(cnt); i++ {
-		if deviceInfoList[i].i8uActive != 0 {
-			g.logger.Debugf("device %d is of type %s is active", i, getModuleName(deviceInfoList[i].i16uModuleType))
-			if deviceInfoList[i].isDIO() {
-				g.logger.Debugf("DIO device info: %v", deviceInfoList[i])
-				g.dioDevices = append(g.dioDevices, deviceInfoList[i])
-			}
-			if deviceInfoList[i].isAIO() {
-				g.logger.Debugf("AIO device info: %v", deviceInfoList[i])
-				g.aioDevices = append(g.aioDevices, deviceInfoList[i])
-			}
+// setDevices stores the active devices that can be used with the board apis for quick reference.
+func (g *gpioChip) setDevices(devices []SDeviceInfo) {
+	dioDevices := []SDeviceInfo{}
+	aioDevices := []SDeviceInfo{}
+	var baseDevice *SDeviceInfo
+	for i, dev := range devices {
+		if dev.i8uActive == 0 {
+			continue
+		}
+		g.logger.Debugf("device %d is of type %s is active", i, getModuleName(dev.i16uModuleType))
+		if dev.isDIO() {
+			g.logger.Debugf("DIO device info: %v", dev)
+			dioDevices = append(dioDevices, dev)
+		}
+		if dev.isAIO() {
+			g.logger.Debugf("AIO device info: %v", dev)
+			aioDevices = append(aioDevices, dev)
+		}
+		if dev.isBase() {
+			g.logger.Debugf("base device info: %v", dev)
+			base := dev
+			baseDevice = &base
+		}
+	}
+
+	g.configMu.Lock()
+	g.devices = devices
+	g.dioDevices = dioDevices
+	g.aioDevices = aioDevices
+	g.baseDevice = baseDevice
+	g.configMu.Unlock()
+
+	// pins on modules that were lost or returned must be initialized again
+	g.clearPins()
+}
+
+// explainMissingDevice replaces the error for a pin that is not on a usable device with a clearer one
+// when the pin belongs to a module that is configured in PiCtory but is not active, or to a module type
+// that cannot be used as the kind of pin requested.
+func (g *gpioChip) explainMissingDevice(pinName, kind string, address uint16, err error) error {
+	g.configMu.RLock()
+	defer g.configMu.RUnlock()
+	for i, dev := range g.devices {
+		if dev.i8uActive != 0 {
+			continue
+		}
+		if _, findErr := findDevice(address, []SDeviceInfo{dev}); findErr == nil {
+			return fmt.Errorf("pin %s is unavailable: %w", pinName, dev.configurationError(i))
+		}
+	}
+	if variable, ok := g.piCtoryVariables[pinName]; ok {
+		return fmt.Errorf("pin %s (%s) is not on a module supported for %ss", pinName, describeVariable(variable), kind)
+	}
+	return err
+}
+
+// getDevices returns every device in the device list, including inactive ones.
+func (g *gpioChip) getDevices() []SDeviceInfo {
+	g.configMu.RLock()
+	defer g.configMu.RUnlock()
+	return g.devices
+}
+
+// getDIODevices returns the active DIO, DI and DO devices.
+func (g *gpioChip) getDIODevices() []SDeviceInfo {
+	g.configMu.RLock()
+	defer g.configMu.RUnlock()
+	return g.dioDevices
+}
+
+// getAIODevices returns the active AIO devices.
+func (g *gpioChip) getAIODevices() []SDeviceInfo {
+	g.configMu.RLock()
+	defer g.configMu.RUnlock()
+	return g.aioDevices
+}
+
+// getBaseDevice returns the base module, or nil if it is not active.
+func (g *gpioChip) getBaseDevice() *SDeviceInfo {
+	g.configMu.RLock()
+	defer g.configMu.RUnlock()
+	return g.baseDevice
+}
+
+// readVariables reads the given variables from the process image in a single read, so that all values come from
+// the same piControl cycle. Each value is decoded using the type of its variable. When the values are read from
+// the latest scan, the time of the scan is returned, otherwise the returned time is zero.
+func (g *gpioChip) readVariables(pins []SPIVariable, direct bool) ([]interface{}, time.Time, error) {
+	if len(pins) == 0 {
+		return []interface{}{}, time.Time{}, nil
+	}
+	types := make([]variableType, 0, len(pins))
+	start, end := pins[0].i16uAddress, pins[0].i16uAddress
+	for _, pin := range pins {
+		t, err := g.typeOf(pin)
+		if err != nil {
+			return nil, time.Time{}, fmt.Errorf("failed to read %s: %w", str32(pin.strVarName), err)
+		}
+		types = append(types, t)
+		start = min(start, pin.i16uAddress)
+		end = max(end, pin.i16uAddress+uint16(t.size))
+	}
+
+	buf := make([]byte, end-start)
+	sampled, err := g.readAt(buf, int64(start), direct)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	values := make([]interface{}, 0, len(pins))
+	for i, pin := range pins {
+		value, err := decodeValue(types[i], buf[pin.i16uAddress-start:], pin.i8uBit)
+		if err != nil {
+			return nil, time.Time{}, fmt.Errorf("failed to read %s: %w", str32(pin.strVarName), err)
+		}
+		values = append(values, value)
+	}
+	return values, sampled, nil
+}
+
+// readAt reads len(buf) bytes of the process image at address, with forced variables replaced by their forced
+// values. When the scan loop is running, the bytes are copied from the latest scan unless direct is set, and the
+// time of the scan is returned. Otherwise the returned time is zero.
+func (g *gpioChip) readAt(buf []byte, address int64, direct bool) (time.Time, error) {
+	sampled, err := g.readUnforced(buf, address, direct)
+	if err != nil {
+		return time.Time{}, err
+	}
+	g.applyForces(buf, address)
+	return sampled, nil
+}
+
+// readUnforced reads len(buf) bytes at address like readAt, without applying the forces.
+func (g *gpioChip) readUnforced(buf []byte, address int64, direct bool) (time.Time, error) {
+	if g.scan != nil && !direct {
+		if sampled, ok := g.scan.read(buf, address); ok {
+			return sampled, nil
+		}
+	}
+	n, err := g.readImage(buf, address)
+	if n != len(buf) {
+		if err != nil {
+			return time.Time{}, err
+		}
+		return time.Time{}, fmt.Errorf("expected %d bytes, got %#v", len(buf), buf[:n])
+	}
+	g.logger.Debugf("Read %#v bytes from %d", buf, address)
+	return time.Time{}, nil
+}
+
+// writeVariable encodes a value using the type of the variable and writes it to the process image,
+// recording api as the origin of the write.
+func (g *gpioChip) writeVariable(pin SPIVariable, value interface{}, api string) error {
+	origin := writeOrigin{api: api, name: str32(pin.strVarName)}
+	t, err := g.typeOf(pin)
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", str32(pin.strVarName), err)
+	}
+
+	// bit variables share their byte with other variables, so only modify the one bit
+	if pin.i16uLength == 1 {
+		high, ok := value.(bool)
+		if !ok {
+			return fmt.Errorf("failed to write %s: expected bool, got %v", str32(pin.strVarName), value)
+		}
+		return g.setBitValue(pin.i16uAddress, pin.i8uBit, high, origin)
+	}
+
+	g.writeMu.Lock()
+	defer g.writeMu.Unlock()
+	current := make([]byte, t.size)
+	if t.name == typeBitField {
+		if _, err := g.readAt(current, int64(pin.i16uAddress), true); err != nil {
+			return err
+		}
+		g.unforce(current, int64(pin.i16uAddress))
+	}
+	b, err := encodeValue(t, value, current)
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", str32(pin.strVarName), err)
+	}
+	return g.writeMasked(int64(pin.i16uAddress), b, nil, origin)
+}
+
+// setBitValue sets a single bit in the process image.
+func (g *gpioChip) setBitValue(address uint16, bitPosition uint8, high bool, origin writeOrigin) error {
+	val := uint8(0)
+	if high {
+		val = uint8(1)
+	}
+	// Because there could be a race in reading the byte with pin states, mutating,
+	// and writing back, we can leverage the ioctl command to modify 1 bit
+	command := SPIValue{i16uAddress: address, i8uBit: bitPosition, i8uValue: val}
+	g.logger.Debugf("Command: %#v", command)
+	g.writeMu.Lock()
+	defer g.writeMu.Unlock()
+	old := g.auditOld(int64(address), 1)
+	written := []byte{val << bitPosition}
+	if err := g.checkWrite(int64(address), written, []byte{1 << bitPosition}); err != nil {
+		g.auditWrite(origin, int64(address), &bitPosition, old, written, false, err)
+		return err
+	}
+	if g.forcedBit(address, bitPosition, high) {
+		g.logger.Debugf("bit %d at address %d is forced, it will be set to %v when the force is cleared", bitPosition, address, high)
+		g.auditWrite(origin, int64(address), &bitPosition, old, written, true, nil)
+		return nil
+	}
+	//nolint:gosec
+	if err := g.ioCtl(uintptr(kbSetValue), unsafe.Pointer(&command)); err != nil {
+		err = fmt.Errorf("failed to set bit %d at address %d: %w", bitPosition, address, err)
+		g.auditWrite(origin, int64(address), &bitPosition, old, written, false, err)
+		return err
+	}
+	g.auditWrite(origin, int64(address), &bitPosition, old, written, false, nil)
+	return nil
+}
+
+// SetOutputs sets digital outputs of a DIO module in a single write of its OutputWord, so that every output
+// changes in the same piControl cycle. All outputs must be on the same module.
+func (g *gpioChip) SetOutputs(outputs map[string]bool) error {
+	names := make([]string, 0, len(outputs))
+	for name := range outputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var outputOffset uint16
+	var setBits, clearBits [2]byte
+	for i, name := range names {
+		pin, err := g.GetGPIOPin(name)
+		if err != nil {
+			return err
+		}
+		address, bit, err := pin.getOutputBit()
+		if err != nil {
+			return err
+		}
+		if i == 0 {
+			outputOffset = pin.outputOffset
+		} else if pin.outputOffset != outputOffset {
+			return fmt.Errorf("cannot set outputs of more than one module at once, %s is not on the same module as %s", name, names[0])
+		}
+		if outputs[name] {
+			setBits[address-outputOffset] |= 1 << bit
 		} else {
-			checkConnected := deviceInfoList[i].i16uModuleType&piControlNotConnected == piControlNotConnected
-			if checkConnected {
-				deviceErr := fmt.Errorf("device %d is not connected", i)
-				deviceErrs = multierr.Combine(deviceErrs, deviceErr)
-			} else {
-				deviceErr := fmt.Errorf("device %d is type %s but is not configured", i, getModuleName(deviceInfoList[i].i16uModuleType))
-				deviceErrs = multierr.Combine(deviceErrs, deviceErr)
-			}
+			clearBits[address-outputOffset] |= 1 << bit
 		}
 	}
-	return deviceErrs
+	if len(names) == 0 {
+		return nil
+	}
+
+	g.writeMu.Lock()
+	defer g.writeMu.Unlock()
+	outputWord := make([]byte, len(setBits))
+	if _, err := g.readAt(outputWord, int64(outputOffset), true); err != nil {
+		return err
+	}
+	g.unforce(outputWord, int64(outputOffset))
+	mask := make([]byte, len(outputWord))
+	for i := range outputWord {
+		outputWord[i] = outputWord[i]&^clearBits[i] | setBits[i]
+		mask[i] = setBits[i] | clearBits[i]
+	}
+	return g.writeMasked(int64(outputOffset), outputWord, mask, writeOrigin{api: setOutputsKey, name: strings.Join(names, ", ")})
 }
 
-func (g *gpioChip) ioCtl(command uintptr, message unsafe.Pointer) syscall.Errno {
+func (g *gpioChip) ioCtl(command uintptr, message unsafe.Pointer) error {
 	_, _, err := g.ioCtlReturns(command, message)
 	return err
 }
 
-func (g *gpioChip) ioCtlReturns(command uintptr, message unsafe.Pointer) (uintptr, uintptr, syscall.Errno) {
-	handle := g.fileHandle.Fd()
-	g.logger.Debugf("Handle: %#v, Command: %#v, Message: %#v", handle, command, message)
-	return unix.Syscall(unix.SYS_IOCTL, handle, command, uintptr(message))
+// ioCtlReturns sends a piControl command, returning ErrBoardClosed if the chip is closed, or the error of the driver
+// along with its last message if the command fails.
+func (g *gpioChip) ioCtlReturns(command uintptr, message unsafe.Pointer) (uintptr, uintptr, error) {
+	r1, r2, errno, err := g.rawIoCtl(command, message)
+	if err != nil {
+		return 0, 0, err
+	}
+	if errno != 0 {
+		return r1, r2, &piControlError{errno: errno, message: g.lastMessage()}
+	}
+	return r1, r2, nil
 }
 
-func (g *gpioChip) getBitValue(address int64, bitPosition uint8) (bool, error) {
-	b := make([]byte, 1)
-	n, err := g.fileHandle.ReadAt(b, address)
-	g.logger.Debugf("Read %#v bytes", b)
-	if n != 1 {
-		return false, fmt.Errorf("expected 1 byte, got %#v", b)
+// rawIoCtl sends a piControl command, returning ErrBoardClosed if the chip is closed and otherwise the results of the
+// syscall.
+func (g *gpioChip) rawIoCtl(command uintptr, message unsafe.Pointer) (uintptr, uintptr, syscall.Errno, error) {
+	g.handleMu.RLock()
+	defer g.handleMu.RUnlock()
+	if g.closed {
+		return 0, 0, 0, ErrBoardClosed
 	}
-	if err != nil {
+	g.logger.Debugf("Device: %v, Command: %#v, Message: %#v", g.dev, command, message)
+	r1, r2, errno := g.fileHandle.ioctl(command, message)
+	return r1, r2, errno, nil
+}
+
+// waitForEvent blocks until piControl reports an event, such as a reset of the driver, or until the waiting thread
+// is interrupted by a signal. It does not hold the handle, so that waiting does not block closing the chip.
+func (g *gpioChip) waitForEvent() (int32, error) {
+	if g.isClosed() {
+		return 0, ErrBoardClosed
+	}
+	var event int32
+	//nolint:gosec
+	if _, _, err := g.fileHandle.ioctl(uintptr(kbWaitForEvent), unsafe.Pointer(&event)); err != 0 {
+		return 0, err
+	}
+	return event, nil
+}
+
+// readImage reads from the process image, keeping the handle open for the duration of the read.
+func (g *gpioChip) readImage(buf []byte, address int64) (int, error) {
+	g.handleMu.RLock()
+	defer g.handleMu.RUnlock()
+	if g.closed {
+		return 0, ErrBoardClosed
+	}
+	return g.fileHandle.ReadAt(buf, address)
+}
+
+// isClosed checks whether the chip was closed.
+func (g *gpioChip) isClosed() bool {
+	g.handleMu.RLock()
+	defer g.handleMu.RUnlock()
+	return g.closed
+}
+
+func (g *gpioChip) getBitValue(address int64, bitPosition uint8, direct bool) (bool, error) {
+	b := make([]byte, 1)
+	if _, err := g.readAt(b, address, direct); err != nil {
 		return false, err
 	}
 	if (b[0]>>bitPosition)&1 == 1 {
@@ -145,7 +643,40 @@ func (g *gpioChip) getBitValue(address int64, bitPosition uint8) (bool, error) {
 	return false, nil
 }
 
-func (g *gpioChip) writeValue(address int64, b []byte) error {
+// writeValue writes b to the process image at address, if the write policy allows it. Bits of forced outputs keep
+// their forced values, and the bits written to them are written when the force is cleared. The write is recorded
+// in the audit log along with its origin.
+func (g *gpioChip) writeValue(address int64, b []byte, origin writeOrigin) error {
+	g.writeMu.Lock()
+	defer g.writeMu.Unlock()
+	return g.writeMasked(address, b, nil, origin)
+}
+
+// writeMasked writes b like writeValue, only checking the bits in mask against the write policy, for writes that
+// also write back bits they read. A nil mask checks every bit of b. It must be called with writeMu held, so that
+// no other write changes the variables the check reads before b is written.
+func (g *gpioChip) writeMasked(address int64, b, mask []byte, origin writeOrigin) error {
+	old := g.auditOld(address, len(b))
+	if err := g.checkWrite(address, b, mask); err != nil {
+		g.auditWrite(origin, address, nil, old, b, false, err)
+		return err
+	}
+	forced := g.forceWrite(b, address)
+	if err := g.writeImage(address, forced); err != nil {
+		g.auditWrite(origin, address, nil, old, b, false, err)
+		return err
+	}
+	g.auditWrite(origin, address, nil, old, b, !bytes.Equal(forced, b), nil)
+	return nil
+}
+
+// writeImage writes to the process image, keeping the handle open for the duration of the write.
+func (g *gpioChip) writeImage(address int64, b []byte) error {
+	g.handleMu.RLock()
+	defer g.handleMu.RUnlock()
+	if g.closed {
+		return ErrBoardClosed
+	}
 	g.logger.Debugf("Writing %#d to %v", b, address)
 	n, err := g.fileHandle.WriteAt(b, address)
 	if err != nil {
@@ -156,9 +687,15 @@ func (g *gpioChip) writeValue(address int64, b []byte) error {
 	return nil
 }
 
+// Close closes the process image once in-flight reads and writes finish. Closing the chip again does nothing.
 func (g *gpioChip) Close() error {
-	err := g.fileHandle.Close()
-	return err
+	g.handleMu.Lock()
+	defer g.handleMu.Unlock()
+	if g.closed {
+		return nil
+	}
+	g.closed = true
+	return g.fileHandle.Close()
 }
 
 func findDevice(address uint16, deviceList []SDeviceInfo) (SDeviceInfo, error) {
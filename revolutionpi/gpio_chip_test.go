@@ -0,0 +1,1006 @@
+//go:build linux
+
+package revolutionpi
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+	"unsafe"
+
+	"go.viam.com/rdk/components/sensor"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/test"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// offsets of the modules in the fake process image.
+const (
+	fakeDIOInputOffset  = 11
+	fakeDIOOutputOffset = fakeDIOInputOffset + 70
+	fakeAIOInputOffset  = 211
+	fakeAIOOutputOffset = fakeAIOInputOffset + 56
+)
+
+// fakeProcessImage is a process image with a RevPi Core, a DIO and an AIO module that implements the piControl
+// ioctls used by the chip. It records any use after it is closed.
+type fakeProcessImage struct {
+	mu             sync.Mutex
+	data           [processImageSize]byte
+	variables      map[string]SPIVariable
+	devices        []SDeviceInfo
+	closed         bool
+	usedAfterClose bool
+	// called after each read, outside the lock, to interleave other operations with the reader
+	afterRead func(address int64)
+	// events reported by kbWaitForEvent
+	events chan int32
+	// returned by every ioctl other than kbGetLastMessage when set
+	ioctlErr syscall.Errno
+}
+
+func newFakeProcessImage() *fakeProcessImage {
+	image := &fakeProcessImage{
+		variables: map[string]SPIVariable{},
+		events:    make(chan int32, 1),
+		devices: []SDeviceInfo{
+			{i8uAddress: 0, i16uModuleType: 95, i8uActive: 1, i16uInputLength: 6, i16uOutputLength: 5},
+			{
+				i8uAddress: 32, i16uModuleType: 96, i8uActive: 1, i32uSerialnumber: 1234,
+				i16uInputOffset: fakeDIOInputOffset, i16uOutputOffset: fakeDIOOutputOffset,
+				i16uInputLength: 70, i16uOutputLength: 18, i16uConfigLength: 112,
+			},
+			{
+				i8uAddress: 31, i16uModuleType: 103, i8uActive: 1,
+				i16uInputOffset: fakeAIOInputOffset, i16uOutputOffset: fakeAIOOutputOffset,
+				i16uInputLength: 56, i16uOutputLength: 18, i16uConfigLength: 94,
+			},
+		},
+	}
+	image.addVariable("I_1", fakeDIOInputOffset, 0, 1)
+	image.addVariable("Counter_1", fakeDIOInputOffset+inputWordToCounterOffset, 0, 32)
+	image.addVariable("O_1", fakeDIOOutputOffset, 0, 1)
+	image.addVariable("O_2", fakeDIOOutputOffset, 1, 1)
+	image.addVariable("O_3", fakeDIOOutputOffset, 2, 1)
+	image.addVariable("InputValue_1", fakeAIOInputOffset, 0, 16)
+	image.addVariable("OutputValue_1", fakeAIOOutputOffset, 0, 16)
+
+	// Counter_1 counts rising edges, and O_3 is a PWM output
+	image.data[fakeDIOInputOffset+inputModeOffset] = 1
+	image.data[fakeDIOInputOffset+outputPWMActiveOffset] = 1 << 2
+	// InputValue_1 measures -10000 to 10000 mV, and OutputValue_1 outputs 0 to 10000 mV
+	image.data[fakeAIOInputOffset+analogInputMemAddress] = 1
+	image.data[fakeAIOInputOffset+69] = 2
+	return image
+}
+
+func (f *fakeProcessImage) addVariable(name string, address uint16, bit uint8, length uint16) {
+	f.variables[name] = SPIVariable{strVarName: char32(name), i16uAddress: address, i8uBit: bit, i16uLength: length}
+}
+
+// checkOpen records a use of the image after it was closed. It must be called with mu held.
+func (f *fakeProcessImage) checkOpen() error {
+	if f.closed {
+		f.usedAfterClose = true
+		return os.ErrClosed
+	}
+	return nil
+}
+
+func (f *fakeProcessImage) ReadAt(buf []byte, address int64) (int, error) {
+	f.mu.Lock()
+	if err := f.checkOpen(); err != nil {
+		f.mu.Unlock()
+		return 0, err
+	}
+	n := copy(buf, f.data[address:])
+	afterRead := f.afterRead
+	f.mu.Unlock()
+	if afterRead != nil {
+		afterRead(address)
+	}
+	return n, nil
+}
+
+func (f *fakeProcessImage) WriteAt(buf []byte, address int64) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.checkOpen(); err != nil {
+		return 0, err
+	}
+	return copy(f.data[address:], buf), nil
+}
+
+func (f *fakeProcessImage) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.checkOpen(); err != nil {
+		return err
+	}
+	f.closed = true
+	return nil
+}
+
+func (f *fakeProcessImage) ioctl(command uintptr, message unsafe.Pointer) (uintptr, uintptr, syscall.Errno) {
+	if int(command) == kbWaitForEvent {
+		// signals cannot interrupt a channel receive, so the wait is interrupted after a while instead
+		select {
+		case event := <-f.events:
+			*(*int32)(message) = event
+			return 0, 0, 0
+		case <-time.After(10 * time.Millisecond):
+			return 0, 0, syscall.EINTR
+		}
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.checkOpen() != nil {
+		return 0, 0, syscall.EBADF
+	}
+	if f.ioctlErr != 0 && int(command) != kbGetLastMessage {
+		return 0, 0, f.ioctlErr
+	}
+	switch int(command) {
+	case kbGetDeviceInfoList:
+		list := (*[255]SDeviceInfo)(message)
+		copy(list[:], f.devices)
+		return uintptr(len(f.devices)), 0, 0
+	case kbFindVariable:
+		pin := (*SPIVariable)(message)
+		variable, ok := f.variables[str32(pin.strVarName)]
+		if !ok {
+			return 0, 0, syscall.ENOENT
+		}
+		*pin = variable
+		return 0, 0, 0
+	case kbSetValue:
+		value := (*SPIValue)(message)
+		f.data[value.i16uAddress] &^= 1 << value.i8uBit
+		f.data[value.i16uAddress] |= (value.i8uValue & 1) << value.i8uBit
+		return 0, 0, 0
+	case kbGetLastMessage:
+		copy((*[piControlMessageLength]byte)(message)[:], "variable not found")
+		return 0, 0, 0
+	default:
+		return 0, 0, syscall.EINVAL
+	}
+}
+
+func newFakeChip(t *testing.T) (*gpioChip, *fakeProcessImage) {
+	t.Helper()
+	image := newFakeProcessImage()
+	chip, _, err := initGpioChip("fake", image, logging.NewTestLogger(t))
+	test.That(t, err, test.ShouldBeNil)
+	return chip, image
+}
+
+func TestPins(t *testing.T) {
+	chip, image := newFakeChip(t)
+	defer chip.Close()
+	ctx := context.Background()
+
+	output, err := chip.GetGPIOPin("O_1")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, output.Set(ctx, true, nil), test.ShouldBeNil)
+	high, err := output.Get(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, high, test.ShouldBeTrue)
+
+	pwm, err := chip.GetGPIOPin("O_3")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, pwm.SetPWM(ctx, 0.5, nil), test.ShouldBeNil)
+	duty, err := pwm.PWM(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, duty, test.ShouldEqual, 0.5)
+
+	err = output.SetPWM(ctx, 0.5, nil)
+	test.That(t, errors.Is(err, ErrNotPWMPin), test.ShouldBeTrue)
+
+	analog, err := chip.GetAnalogPin("OutputValue_1")
+	test.That(t, err, test.ShouldBeNil)
+	err = analog.Write(ctx, 20000, nil)
+	test.That(t, errors.Is(err, ErrOutOfRange), test.ShouldBeTrue)
+	// outputs are 16 bits, so writing one leaves the next output unchanged
+	image.data[fakeAIOOutputOffset+2] = 0xaa
+	image.data[fakeAIOOutputOffset+3] = 0x55
+	test.That(t, analog.Write(ctx, 5000, nil), test.ShouldBeNil)
+	test.That(t, image.data[fakeAIOOutputOffset:fakeAIOOutputOffset+4], test.ShouldResemble, []byte{0x88, 0x13, 0xaa, 0x55})
+
+	_, err = chip.GetGPIOPin("O_l")
+	test.That(t, errors.Is(err, ErrVariableNotFound), test.ShouldBeTrue)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "piControl: variable not found")
+}
+
+func TestSetOutputs(t *testing.T) {
+	chip, image := newFakeChip(t)
+	defer chip.Close()
+
+	test.That(t, chip.SetOutputs(map[string]bool{"O_1": true, "O_2": true}), test.ShouldBeNil)
+	test.That(t, chip.SetOutputs(map[string]bool{"O_1": false}), test.ShouldBeNil)
+	test.That(t, image.data[fakeDIOOutputOffset], test.ShouldEqual, 0b10)
+
+	// O_3 is configured as PWM, so the whole write is rejected
+	test.That(t, chip.SetOutputs(map[string]bool{"O_1": true, "O_3": true}), test.ShouldNotBeNil)
+	test.That(t, image.data[fakeDIOOutputOffset], test.ShouldEqual, 0b10)
+}
+
+func TestIoctlErrors(t *testing.T) {
+	chip, image := newFakeChip(t)
+	// an error of the driver is returned as is, with the last message of the driver
+	image.mu.Lock()
+	image.ioctlErr = syscall.EBADF
+	image.mu.Unlock()
+	_, err := chip.getDeviceList()
+	test.That(t, errors.Is(err, syscall.EBADF), test.ShouldBeTrue)
+	test.That(t, errors.Is(err, ErrBoardClosed), test.ShouldBeFalse)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "piControl: variable not found")
+
+	test.That(t, chip.Close(), test.ShouldBeNil)
+	_, err = chip.getDeviceList()
+	test.That(t, errors.Is(err, ErrBoardClosed), test.ShouldBeTrue)
+}
+
+func TestConcurrentUseAndClose(t *testing.T) {
+	chip, image := newFakeChip(t)
+	ctx := context.Background()
+
+	output, err := chip.GetGPIOPin("O_1")
+	test.That(t, err, test.ShouldBeNil)
+	pwm, err := chip.GetGPIOPin("O_3")
+	test.That(t, err, test.ShouldBeNil)
+	analog, err := chip.GetAnalogPin("InputValue_1")
+	test.That(t, err, test.ShouldBeNil)
+	counter, err := chip.GetDigitalInterrupt("Counter_1")
+	test.That(t, err, test.ShouldBeNil)
+
+	// every operation either succeeds or reports that the board was closed
+	checkErr := func(err error) {
+		if err != nil && !errors.Is(err, ErrBoardClosed) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	operations := []func(i int){
+		func(i int) { checkErr(output.Set(ctx, i%2 == 0, nil)) },
+		func(i int) {
+			_, err := output.Get(ctx, nil)
+			checkErr(err)
+		},
+		func(i int) { checkErr(pwm.SetPWM(ctx, float64(i%100)/100, nil)) },
+		func(i int) {
+			_, err := analog.Read(ctx, nil)
+			checkErr(err)
+		},
+		func(i int) {
+			_, err := counter.Value()
+			checkErr(err)
+		},
+		func(i int) { checkErr(chip.SetOutputs(map[string]bool{"O_1": i%2 == 0, "O_2": i%3 == 0})) },
+		func(i int) {
+			_, err := chip.GetGPIOPin("O_2")
+			checkErr(err)
+		},
+		func(i int) {
+			if i%50 == 0 {
+				checkErr(chip.reloadConfiguration())
+			}
+		},
+	}
+
+	// the chip is closed by whichever operation is first halfway done, while the others are still running
+	var wg sync.WaitGroup
+	var closeOnce sync.Once
+	var closeErr error
+	start := make(chan struct{})
+	for _, operation := range operations {
+		wg.Add(1)
+		go func(operation func(i int)) {
+			defer wg.Done()
+			<-start
+			for i := 0; i < 200; i++ {
+				if i == 100 {
+					closeOnce.Do(func() { closeErr = chip.Close() })
+				}
+				operation(i)
+			}
+		}(operation)
+	}
+	close(start)
+	wg.Wait()
+	test.That(t, closeErr, test.ShouldBeNil)
+
+	test.That(t, image.usedAfterClose, test.ShouldBeFalse)
+	test.That(t, chip.Close(), test.ShouldBeNil)
+	err = output.Set(ctx, true, nil)
+	test.That(t, errors.Is(err, ErrBoardClosed), test.ShouldBeTrue)
+	_, err = analog.Read(ctx, nil)
+	test.That(t, errors.Is(err, ErrBoardClosed), test.ShouldBeTrue)
+}
+
+func TestScanLoop(t *testing.T) {
+	chip, image := newFakeChip(t)
+	defer chip.Close()
+	chip.scan = newScanLoop(chip.readImage, defaultDeviceMonitorInterval, chip.logger)
+	test.That(t, chip.scan.scan(), test.ShouldBeNil)
+
+	input, err := chip.GetGPIOPin("I_1")
+	test.That(t, err, test.ShouldBeNil)
+	image.mu.Lock()
+	image.data[fakeDIOInputOffset] = 1
+	image.mu.Unlock()
+
+	// reads are served from the scan until the next scan, unless they are direct
+	high, err := input.Get(context.Background(), nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, high, test.ShouldBeFalse)
+	high, err = input.Get(context.Background(), map[string]interface{}{directKey: true})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, high, test.ShouldBeTrue)
+
+	test.That(t, chip.scan.scan(), test.ShouldBeNil)
+	high, err = input.Get(context.Background(), nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, high, test.ShouldBeTrue)
+}
+
+func TestForces(t *testing.T) {
+	chip, image := newFakeChip(t)
+	defer chip.Close()
+	ctx := context.Background()
+
+	input, err := chip.GetGPIOPin("I_1")
+	test.That(t, err, test.ShouldBeNil)
+	output, err := chip.GetGPIOPin("O_1")
+	test.That(t, err, test.ShouldBeNil)
+
+	// forced inputs read as their forced value, even when read directly
+	test.That(t, chip.forceVariable("I_1", true, time.Minute), test.ShouldBeNil)
+	high, err := input.Get(ctx, map[string]interface{}{directKey: true})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, high, test.ShouldBeTrue)
+	test.That(t, image.data[fakeDIOInputOffset], test.ShouldEqual, 0)
+
+	// writes to forced outputs are held back until the force is cleared
+	test.That(t, chip.forceVariable("O_1", true, time.Minute), test.ShouldBeNil)
+	test.That(t, image.data[fakeDIOOutputOffset], test.ShouldEqual, 0b1)
+	test.That(t, output.Set(ctx, false, nil), test.ShouldBeNil)
+	test.That(t, chip.SetOutputs(map[string]bool{"O_2": true}), test.ShouldBeNil)
+	test.That(t, image.data[fakeDIOOutputOffset], test.ShouldEqual, 0b11)
+	test.That(t, chip.forceVariable("OutputValue_1", 5000.0, time.Minute), test.ShouldBeNil)
+	test.That(t, len(chip.listForces()), test.ShouldEqual, 3)
+
+	cleared, err := chip.clearForces([]string{"O_1"}, "test")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, cleared, test.ShouldResemble, []string{"O_1"})
+	test.That(t, image.data[fakeDIOOutputOffset], test.ShouldEqual, 0b10)
+
+	// expired forces are cleared
+	chip.expireForces(time.Now().Add(time.Hour))
+	test.That(t, chip.listForces(), test.ShouldBeEmpty)
+	high, err = input.Get(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, high, test.ShouldBeFalse)
+	test.That(t, image.data[fakeAIOOutputOffset], test.ShouldEqual, 0)
+}
+
+func TestDropForces(t *testing.T) {
+	b, image := newFakeBoard(t)
+	defer b.Close(context.Background())
+	chip := b.controlChip
+	test.That(t, chip.forceVariable("O_1", true, time.Minute), test.ShouldBeNil)
+	test.That(t, chip.forceVariable("O_2", true, time.Minute), test.ShouldBeNil)
+	test.That(t, image.data[fakeDIOOutputOffset], test.ShouldEqual, 0b11)
+
+	// O_2 keeps its address and is restored, while the output O_1 was forced on now belongs to another variable
+	image.mu.Lock()
+	image.addVariable("O_1", fakeDIOOutputOffset, 3, 1)
+	image.mu.Unlock()
+	test.That(t, chip.reloadConfiguration(), test.ShouldBeNil)
+	test.That(t, chip.listForces(), test.ShouldBeEmpty)
+	test.That(t, image.data[fakeDIOOutputOffset], test.ShouldEqual, 0b01)
+
+	entries := chip.audit.recent(2)
+	test.That(t, entries, test.ShouldHaveLength, 2)
+	left := entries[0].(map[string]interface{})
+	test.That(t, left["api"], test.ShouldEqual, auditAPIUnforce)
+	test.That(t, left["name"], test.ShouldEqual, "O_1")
+	test.That(t, left["forced"], test.ShouldBeTrue)
+	test.That(t, left["error"], test.ShouldContainSubstring, "forced value true was left at address 81")
+	restored := entries[1].(map[string]interface{})
+	test.That(t, restored["name"], test.ShouldEqual, "O_2")
+	test.That(t, restored["new"], test.ShouldEqual, false)
+}
+
+func TestWritePolicy(t *testing.T) {
+	chip, image := newFakeChip(t)
+	defer chip.Close()
+	ctx := context.Background()
+	maxValue := 5000.0
+	chip.policy = newWritePolicy(false, true, map[string]WritePolicyConfig{
+		"O_1":           {Policy: writePolicyAllow},
+		"O_2":           {Policy: writePolicyDeny},
+		"OutputValue_1": {Policy: writePolicyRange, Max: &maxValue},
+	})
+
+	output, err := chip.GetGPIOPin("O_1")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, output.Set(ctx, true, nil), test.ShouldBeNil)
+	denied, err := chip.GetGPIOPin("O_2")
+	test.That(t, err, test.ShouldBeNil)
+	err = denied.Set(ctx, true, nil)
+	test.That(t, errors.Is(err, ErrWriteDenied), test.ShouldBeTrue)
+
+	// setOutputs only checks the outputs it sets, although it writes back the whole OutputWord
+	test.That(t, chip.SetOutputs(map[string]bool{"O_1": false}), test.ShouldBeNil)
+	err = chip.SetOutputs(map[string]bool{"O_1": true, "O_2": true})
+	test.That(t, errors.Is(err, ErrWriteDenied), test.ShouldBeTrue)
+	test.That(t, image.data[fakeDIOOutputOffset], test.ShouldEqual, 0)
+
+	analog, err := chip.GetAnalogPin("OutputValue_1")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, analog.Write(ctx, 4000, nil), test.ShouldBeNil)
+	err = analog.Write(ctx, 6000, nil)
+	test.That(t, errors.Is(err, ErrWriteDenied), test.ShouldBeTrue)
+
+	// outputs without a policy are denied by default
+	pwm, err := chip.GetGPIOPin("O_3")
+	test.That(t, err, test.ShouldBeNil)
+	err = pwm.SetPWM(ctx, 0.5, nil)
+	test.That(t, errors.Is(err, ErrWriteDenied), test.ShouldBeTrue)
+
+	// a policy that cannot be resolved rejects every write instead of leaving its variable unprotected
+	chip.policy = newWritePolicy(false, false, map[string]WritePolicyConfig{"O_9": {Policy: writePolicyDeny}})
+	err = output.Set(ctx, true, nil)
+	test.That(t, errors.Is(err, ErrWriteDenied), test.ShouldBeTrue)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "the write policy of O_9 cannot be applied")
+
+	chip.policy = newWritePolicy(true, false, nil)
+	err = output.Set(ctx, true, nil)
+	test.That(t, errors.Is(err, ErrWriteDenied), test.ShouldBeTrue)
+	err = chip.forceVariable("O_1", true, time.Minute)
+	test.That(t, errors.Is(err, ErrWriteDenied), test.ShouldBeTrue)
+}
+
+func TestAuditLog(t *testing.T) {
+	chip, _ := newFakeChip(t)
+	defer chip.Close()
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "audit.log")
+	audit, err := newAuditLog(path, 0.0005, chip.logger)
+	test.That(t, err, test.ShouldBeNil)
+	defer audit.Close()
+	chip.audit = audit
+
+	output, err := chip.GetGPIOPin("O_1")
+	test.That(t, err, test.ShouldBeNil)
+	analog, err := chip.GetAnalogPin("OutputValue_1")
+	test.That(t, err, test.ShouldBeNil)
+	for i := 0; i < 5; i++ {
+		test.That(t, output.Set(ctx, i%2 == 0, nil), test.ShouldBeNil)
+		test.That(t, analog.Write(ctx, 1000*i, nil), test.ShouldBeNil)
+	}
+
+	entries := audit.recent(2)
+	test.That(t, entries, test.ShouldHaveLength, 2)
+	test.That(t, entries[0], test.ShouldContainKey, "bit")
+	set := entries[0].(map[string]interface{})
+	test.That(t, set["api"], test.ShouldEqual, "Set")
+	test.That(t, set["name"], test.ShouldEqual, "O_1")
+	test.That(t, set["old"], test.ShouldEqual, false)
+	test.That(t, set["new"], test.ShouldEqual, true)
+	write := entries[1].(map[string]interface{})
+	test.That(t, write["api"], test.ShouldEqual, "Write")
+	test.That(t, write["old"], test.ShouldEqual, uint32(3000))
+	test.That(t, write["new"], test.ShouldEqual, uint32(4000))
+	test.That(t, audit.recent(0), test.ShouldHaveLength, 10)
+
+	// the file is rotated once it is full
+	_, err = os.Stat(path + ".1")
+	test.That(t, err, test.ShouldBeNil)
+
+	// failed writes are recorded with their error
+	test.That(t, chip.Close(), test.ShouldBeNil)
+	test.That(t, analog.Write(ctx, 1000, nil), test.ShouldNotBeNil)
+	failed := audit.recent(1)[0].(map[string]interface{})
+	test.That(t, failed["error"], test.ShouldEqual, ErrBoardClosed.Error())
+	test.That(t, failed, test.ShouldNotContainKey, "denied")
+}
+
+func TestInterlocks(t *testing.T) {
+	chip, image := newFakeChip(t)
+	defer chip.Close()
+	ctx := context.Background()
+	on, limit := true, 5000.0
+	chip.interlocks = newInterlocks([]InterlockConfig{
+		{
+			Name:  "forward and reverse",
+			Write: InterlockCondition{Variable: "O_1", Is: &on},
+			When:  []InterlockCondition{{Variable: "O_2", Is: &on}},
+		},
+		{
+			Name:  "high level",
+			Write: InterlockCondition{Variable: "OutputValue_1", Above: &limit},
+			When:  []InterlockCondition{{Variable: "I_1", Is: &on}},
+		},
+	})
+
+	forward, err := chip.GetGPIOPin("O_1")
+	test.That(t, err, test.ShouldBeNil)
+	reverse, err := chip.GetGPIOPin("O_2")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, forward.Set(ctx, true, nil), test.ShouldBeNil)
+	test.That(t, forward.Set(ctx, false, nil), test.ShouldBeNil)
+	test.That(t, reverse.Set(ctx, true, nil), test.ShouldBeNil)
+	err = forward.Set(ctx, true, nil)
+	test.That(t, errors.Is(err, ErrInterlocked), test.ShouldBeTrue)
+	test.That(t, err.Error(), test.ShouldContainSubstring, `"forward and reverse"`)
+
+	// outputs written together are checked with their new values
+	test.That(t, chip.SetOutputs(map[string]bool{"O_1": true, "O_2": false}), test.ShouldBeNil)
+	test.That(t, image.data[fakeDIOOutputOffset], test.ShouldEqual, 0b01)
+
+	analog, err := chip.GetAnalogPin("OutputValue_1")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, analog.Write(ctx, 8000, nil), test.ShouldBeNil)
+	test.That(t, chip.forceVariable("I_1", true, time.Minute), test.ShouldBeNil)
+	defer chip.clearForces(nil, "test")
+	test.That(t, analog.Write(ctx, 4000, nil), test.ShouldBeNil)
+	err = analog.Write(ctx, 8000, nil)
+	test.That(t, errors.Is(err, ErrInterlocked), test.ShouldBeTrue)
+
+	// restoring a forced output is checked like any other write, and keeps the forced value if it is rejected
+	test.That(t, forward.Set(ctx, false, nil), test.ShouldBeNil)
+	test.That(t, chip.forceVariable("O_1", false, time.Minute), test.ShouldBeNil)
+	test.That(t, forward.Set(ctx, true, nil), test.ShouldBeNil)
+	test.That(t, reverse.Set(ctx, true, nil), test.ShouldBeNil)
+	_, err = chip.clearForces([]string{"O_1"}, "test")
+	test.That(t, errors.Is(err, ErrInterlocked), test.ShouldBeTrue)
+	test.That(t, image.data[fakeDIOOutputOffset], test.ShouldEqual, 0b10)
+}
+
+func newFakeBoard(t *testing.T) (*revolutionPiBoard, *fakeProcessImage) {
+	t.Helper()
+	chip, image := newFakeChip(t)
+	audit, err := newAuditLog("", 0, chip.logger)
+	test.That(t, err, test.ShouldBeNil)
+	chip.audit = audit
+	cancelCtx, cancelFunc := context.WithCancel(context.Background())
+	return &revolutionPiBoard{logger: chip.logger, controlChip: chip, cancelCtx: cancelCtx, cancelFunc: cancelFunc}, image
+}
+
+func TestPulses(t *testing.T) {
+	b, image := newFakeBoard(t)
+	ctx := context.Background()
+	outputBit := func(bit uint) bool {
+		image.mu.Lock()
+		defer image.mu.Unlock()
+		return image.data[fakeDIOOutputOffset]&(1<<bit) != 0
+	}
+
+	pin, err := b.GPIOPinByName("O_1")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, pin.Set(ctx, true, map[string]interface{}{pulseDurationKey: 20.0}), test.ShouldBeNil)
+	test.That(t, outputBit(0), test.ShouldBeTrue)
+	deadline := time.Now().Add(5 * time.Second)
+	for outputBit(0) && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	test.That(t, outputBit(0), test.ShouldBeFalse)
+	test.That(t, b.pulseStatus(), test.ShouldBeEmpty)
+
+	// a write that replaces a pulse but is rejected leaves the pulse running, so the output still reverts
+	on := true
+	b.controlChip.interlocks = newInterlocks([]InterlockConfig{
+		{Name: "guard", Write: InterlockCondition{Variable: "O_1", Is: &on}, When: []InterlockCondition{{Variable: "I_1", Is: &on}}},
+	})
+	test.That(t, pin.Set(ctx, true, nil), test.ShouldBeNil)
+	test.That(t, pin.Set(ctx, false, map[string]interface{}{pulseDurationKey: 300.0}), test.ShouldBeNil)
+	image.mu.Lock()
+	image.data[fakeDIOInputOffset] = 1
+	image.mu.Unlock()
+	err = pin.Set(ctx, true, nil)
+	test.That(t, errors.Is(err, ErrInterlocked), test.ShouldBeTrue)
+	_, err = b.DoCommand(ctx, map[string]interface{}{writeParameterKey: map[string]interface{}{"name": "O_1", "value": true}})
+	test.That(t, errors.Is(err, ErrInterlocked), test.ShouldBeTrue)
+	_, err = b.DoCommand(ctx, map[string]interface{}{setOutputsKey: map[string]interface{}{"O_1": true}})
+	test.That(t, errors.Is(err, ErrInterlocked), test.ShouldBeTrue)
+	test.That(t, b.pulseStatus(), test.ShouldHaveLength, 1)
+	image.mu.Lock()
+	image.data[fakeDIOInputOffset] = 0
+	image.mu.Unlock()
+	deadline = time.Now().Add(5 * time.Second)
+	for !outputBit(0) && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	test.That(t, outputBit(0), test.ShouldBeTrue)
+	test.That(t, b.pulseStatus(), test.ShouldBeEmpty)
+	b.controlChip.interlocks = nil
+
+	// a pulse replacing another keeps the value from before the first pulse, and closing the board reverts it
+	_, err = b.DoCommand(ctx, map[string]interface{}{pulseKey: map[string]interface{}{"name": "O_2", "duration_ms": 3600000.0}})
+	test.That(t, err, test.ShouldBeNil)
+	_, err = b.DoCommand(ctx, map[string]interface{}{pulseKey: map[string]interface{}{"name": "O_2", "duration_ms": 3600000.0}})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, outputBit(1), test.ShouldBeTrue)
+	test.That(t, b.pulseStatus()[0].(map[string]interface{})["revert_to"], test.ShouldBeFalse)
+	test.That(t, b.Close(ctx), test.ShouldBeNil)
+	test.That(t, outputBit(1), test.ShouldBeFalse)
+}
+
+func TestPulseTrains(t *testing.T) {
+	b, image := newFakeBoard(t)
+	ctx := context.Background()
+	// a 5 ms IO cycle
+	image.data[baseIOCycleOffset] = 5
+
+	err := b.startPulseTrain("O_1", 2*time.Millisecond, 10*time.Millisecond, 3)
+	test.That(t, errors.Is(err, ErrOutOfRange), test.ShouldBeTrue)
+
+	_, err = b.DoCommand(ctx, map[string]interface{}{
+		startPulseTrainKey: map[string]interface{}{"name": "O_1", "on_ms": 10.0, "off_ms": 10.0, "count": 3.0},
+	})
+	test.That(t, err, test.ShouldBeNil)
+	deadline := time.Now().Add(5 * time.Second)
+	for len(b.pulseTrainStatus()) > 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	test.That(t, b.pulseTrainStatus(), test.ShouldBeEmpty)
+	ons := 0
+	for _, entry := range b.controlChip.audit.recent(0) {
+		e := entry.(map[string]interface{})
+		if e["api"] == startPulseTrainKey && e["new"] == true {
+			ons++
+		}
+	}
+	test.That(t, ons, test.ShouldEqual, 3)
+	test.That(t, image.data[fakeDIOOutputOffset]&1, test.ShouldEqual, 0)
+
+	// a continuous train runs until it is stopped, or until the output is written
+	test.That(t, b.startPulseTrain("O_1", 10*time.Millisecond, 10*time.Millisecond, 0), test.ShouldBeNil)
+	test.That(t, b.startPulseTrain("O_2", 10*time.Millisecond, 10*time.Millisecond, 0), test.ShouldBeNil)
+	resp, err := b.DoCommand(ctx, map[string]interface{}{stopPulseTrainKey: "O_1"})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, resp[stopPulseTrainKey], test.ShouldResemble, []interface{}{"O_1"})
+	pin, err := b.GPIOPinByName("O_2")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, pin.Set(ctx, true, nil), test.ShouldBeNil)
+	test.That(t, b.pulseTrainStatus(), test.ShouldBeEmpty)
+	test.That(t, b.Close(ctx), test.ShouldBeNil)
+}
+
+func TestDebounce(t *testing.T) {
+	b, image := newFakeBoard(t)
+	defer b.Close(context.Background())
+	ctx := context.Background()
+	var err error
+	b.debouncer, err = newDebounceManager(b.controlChip, map[string]float64{"I_1": 20}, b.logger)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, b.debouncer.interval, test.ShouldEqual, 5*time.Millisecond)
+	setInput := func(high bool) {
+		image.mu.Lock()
+		defer image.mu.Unlock()
+		image.data[fakeDIOInputOffset] = 0
+		if high {
+			image.data[fakeDIOInputOffset] = 1
+		}
+	}
+
+	// the input bounces before settling high 10 ms after it first changed
+	start := time.Now()
+	for i, high := range []bool{true, false, true} {
+		setInput(high)
+		b.debouncer.sample(ctx, start.Add(time.Duration(i)*5*time.Millisecond))
+	}
+	b.debouncer.sample(ctx, start.Add(25*time.Millisecond))
+	pin, err := b.GPIOPinByName("I_1")
+	test.That(t, err, test.ShouldBeNil)
+	debounced, err := pin.Get(ctx, map[string]interface{}{debouncedKey: true})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, debounced, test.ShouldBeFalse)
+	raw, err := pin.Get(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, raw, test.ShouldBeTrue)
+
+	b.debouncer.sample(ctx, start.Add(30*time.Millisecond))
+	debounced, err = pin.Get(ctx, map[string]interface{}{debouncedKey: true})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, debounced, test.ShouldBeTrue)
+	resp, err := b.DoCommand(ctx, map[string]interface{}{getEdgesKey: "I_1"})
+	test.That(t, err, test.ShouldBeNil)
+	edges := resp[getEdgesKey].(map[string]interface{})["I_1"].(map[string]interface{})
+	test.That(t, edges["last_rising"], test.ShouldEqual, start.Add(10*time.Millisecond).Format(time.RFC3339Nano))
+	test.That(t, edges["last_falling"], test.ShouldBeNil)
+	test.That(t, edges["rising_edges"], test.ShouldEqual, uint64(1))
+}
+
+func TestWatchDeviceLayout(t *testing.T) {
+	b, image := newFakeBoard(t)
+	changes := make(chan struct{}, 1)
+	b.activeBackgroundWorkers.Add(1)
+	go func() {
+		defer b.activeBackgroundWorkers.Done()
+		b.watchDeviceLayout(changes)
+	}()
+
+	// a lost module keeps its layout, while a module moved by a new configuration changes it
+	image.mu.Lock()
+	image.devices[2].i16uModuleType |= piControlNotConnected
+	image.mu.Unlock()
+	select {
+	case <-changes:
+		t.Fatal("a lost module was reported as a new layout")
+	case <-time.After(2 * deviceLayoutPollInterval):
+	}
+	image.mu.Lock()
+	image.devices[2].i16uInputOffset += 10
+	image.mu.Unlock()
+	select {
+	case <-changes:
+	case <-time.After(5 * deviceLayoutPollInterval):
+		t.Fatal("the new layout was not reported")
+	}
+
+	// the watcher is stopped when the board is closed
+	test.That(t, b.Close(context.Background()), test.ShouldBeNil)
+}
+
+func TestWaitForResets(t *testing.T) {
+	b, image := newFakeBoard(t)
+	changes := make(chan struct{}, 1)
+	b.resetWaiterDone = make(chan struct{})
+	b.activeBackgroundWorkers.Add(1)
+	go func() {
+		defer b.activeBackgroundWorkers.Done()
+		b.waitForResets(changes)
+	}()
+
+	// a reset is reported even though the layout of the modules did not change
+	image.events <- piControlEventReset
+	select {
+	case <-changes:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the reset was not reported")
+	}
+
+	// the waiter is stopped when the board is closed
+	test.That(t, b.Close(context.Background()), test.ShouldBeNil)
+	select {
+	case <-b.resetWaiterDone:
+	default:
+		t.Fatal("the waiter was not stopped")
+	}
+}
+
+func TestConcurrentInterlocks(t *testing.T) {
+	chip, image := newFakeChip(t)
+	defer chip.Close()
+	ctx := context.Background()
+	on, limit := true, 5000.0
+	// O_1 and a high OutputValue_1 exclude each other, whichever is written first
+	chip.interlocks = newInterlocks([]InterlockConfig{
+		{
+			Name:  "output on",
+			Write: InterlockCondition{Variable: "O_1", Is: &on},
+			When:  []InterlockCondition{{Variable: "OutputValue_1", Above: &limit}},
+		},
+		{
+			Name:  "high level",
+			Write: InterlockCondition{Variable: "OutputValue_1", Above: &limit},
+			When:  []InterlockCondition{{Variable: "O_1", Is: &on}},
+		},
+	})
+	output, err := chip.GetGPIOPin("O_1")
+	test.That(t, err, test.ShouldBeNil)
+	analog, err := chip.GetAnalogPin("OutputValue_1")
+	test.That(t, err, test.ShouldBeNil)
+
+	// O_1 is set right after the analog write checked it, before the analog write is made
+	setErr := make(chan error, 1)
+	var armed atomic.Bool
+	armed.Store(true)
+	image.mu.Lock()
+	image.afterRead = func(address int64) {
+		if address != fakeDIOOutputOffset || !armed.CompareAndSwap(true, false) {
+			return
+		}
+		go func() { setErr <- output.Set(ctx, true, nil) }()
+		select {
+		case err := <-setErr:
+			setErr <- err
+			t.Error("O_1 was set between the check and the write of OutputValue_1")
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+	image.mu.Unlock()
+
+	test.That(t, analog.Write(ctx, 8000, nil), test.ShouldBeNil)
+	test.That(t, errors.Is(<-setErr, ErrInterlocked), test.ShouldBeTrue)
+	test.That(t, image.data[fakeDIOOutputOffset]&1, test.ShouldEqual, 0)
+}
+
+func TestDecodeValue(t *testing.T) {
+	buf := []byte{0xfe, 0xff, 0xff, 0xff}
+	for _, tc := range []struct {
+		typ      string
+		expected interface{}
+	}{
+		{typeInt8, int32(-2)},
+		{typeInt16, int32(-2)},
+		{typeInt32, int32(-2)},
+		{typeUint8, uint32(0xfe)},
+		{typeUint16, uint32(0xfffe)},
+		{typeUint32, uint32(0xfffffffe)},
+	} {
+		typ, err := VariableTypeConfig{Type: tc.typ}.resolve(32)
+		test.That(t, err, test.ShouldBeNil)
+		value, err := decodeValue(typ, buf, 0)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, value, test.ShouldEqual, tc.expected)
+		// decoded values are returned in readings and DoCommand responses, so they must convert to protobuf
+		_, err = structpb.NewValue(value)
+		test.That(t, err, test.ShouldBeNil)
+	}
+}
+
+func TestSensorReadings(t *testing.T) {
+	chip, image := newFakeChip(t)
+	defer chip.Close()
+	scale := 0.5
+	variables := []SensorVariableConfig{
+		{Name: "I_1"},
+		{Name: "Counter_1", Scale: &scale, Units: "l"},
+		{Name: "InputValue_1", Type: typeInt16},
+		{Name: "OutputValue_1", Type: typeBitField, Bits: map[string]uint8{"first": 0, "last": 15}},
+	}
+	s := &revolutionPiSensor{chip: chip, variables: variables}
+	for _, variable := range variables {
+		if variable.Type != "" {
+			chip.variableTypes[variable.Name] = variable.typeConfig()
+		}
+		pin := SPIVariable{strVarName: char32(variable.Name)}
+		test.That(t, chip.mapNameToAddress(&pin), test.ShouldBeNil)
+		s.pins = append(s.pins, pin)
+	}
+	image.data[fakeDIOInputOffset] = 1
+	image.data[fakeDIOInputOffset+inputWordToCounterOffset] = 10
+	image.data[fakeAIOInputOffset] = 0xfe
+	image.data[fakeAIOInputOffset+1] = 0xff
+	image.data[fakeAIOOutputOffset+1] = 0x80
+
+	readings, err := s.Readings(context.Background(), nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, readings, test.ShouldResemble, map[string]interface{}{
+		"I_1":             true,
+		"Counter_1":       5.0,
+		"Counter_1_units": "l",
+		"InputValue_1":    int64(-2),
+		"OutputValue_1":   map[string]interface{}{"raw": int64(0x8000), "first": false, "last": true},
+	})
+	// readings are sent over grpc, so every value must convert to protobuf
+	_, err = structpb.NewStruct(readings)
+	test.That(t, err, test.ShouldBeNil)
+}
+
+func TestHealthReadings(t *testing.T) {
+	image := newFakeProcessImage()
+	// the AIO module is configured but missing, which the health sensor must still report on
+	image.devices[2].i16uModuleType |= piControlNotConnected
+	image.devices[2].i8uActive = 0
+	chip, _, err := initGpioChip("fake", image, logging.NewTestLogger(t))
+	test.That(t, err, test.ShouldBeNil)
+	defer chip.Close()
+	h, err := newRevolutionPiHealth(resource.NewName(sensor.API, "health"), chip, nil)
+	test.That(t, err, test.ShouldBeNil)
+
+	image.data[baseStatusOffset] = statusRunning | statusModuleMissing
+	image.data[baseIOCycleOffset] = 5
+	image.data[baseRS485ErrorCntOffset] = 2
+	image.data[baseTemperatureOffset] = 48
+	image.data[baseFrequencyOffset] = 120
+
+	readings, err := h.Readings(context.Background(), nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, readings["status"], test.ShouldEqual, 5)
+	test.That(t, readings["running"], test.ShouldBeTrue)
+	test.That(t, readings["module_missing"], test.ShouldBeTrue)
+	test.That(t, readings["io_cycle_ms"], test.ShouldEqual, 5)
+	test.That(t, readings["pibridge_error_count"], test.ShouldEqual, 2)
+	test.That(t, readings["core_temperature_c"], test.ShouldEqual, 48)
+	test.That(t, readings["core_frequency_mhz"], test.ShouldEqual, 1200)
+	test.That(t, readings["base_module"], test.ShouldEqual, "RevPi Core")
+	// readings are sent over grpc, so every value must convert to protobuf
+	_, err = structpb.NewStruct(readings)
+	test.That(t, err, test.ShouldBeNil)
+}
+
+func TestTotalizerResetWhileStopped(t *testing.T) {
+	dir := t.TempDir()
+	oldBootIDPath := bootIDPath
+	bootIDPath = filepath.Join(dir, "boot_id")
+	defer func() { bootIDPath = oldBootIDPath }()
+	test.That(t, os.WriteFile(bootIDPath, []byte("current-boot\n"), 0o600), test.ShouldBeNil)
+	stateFile := filepath.Join(dir, "totalizers.json")
+	chip, image := newFakeChip(t)
+	defer chip.Close()
+	image.data[fakeDIOInputOffset+inputWordToCounterOffset] = 20
+
+	for _, tc := range []struct {
+		name          string
+		state         string
+		total         uint64
+		counterResets int
+	}{
+		{"same counter", `{"Counter_1": {"last_count": 5, "total": 100, "boot_id": "current-boot", "serial": 1234}}`, 115, 0},
+		{"rebooted", `{"Counter_1": {"last_count": 5, "total": 100, "boot_id": "previous-boot", "serial": 1234}}`, 120, 1},
+		{"module replaced", `{"Counter_1": {"last_count": 5, "total": 100, "boot_id": "current-boot", "serial": 4321}}`, 120, 1},
+		{"count went down", `{"Counter_1": {"last_count": 30, "total": 100}}`, 120, 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			test.That(t, os.WriteFile(stateFile, []byte(tc.state), 0o600), test.ShouldBeNil)
+			m, err := newTotalizerManager(chip, []string{"Counter_1"}, stateFile, chip.logger)
+			test.That(t, err, test.ShouldBeNil)
+			totals := m.totals()["Counter_1"].(map[string]interface{})
+			test.That(t, totals["total"], test.ShouldEqual, tc.total)
+			test.That(t, totals["counter_resets"], test.ShouldEqual, tc.counterResets)
+		})
+	}
+}
+
+func TestEncoderDoCommand(t *testing.T) {
+	chip, image := newFakeChip(t)
+	defer chip.Close()
+	// Counter_1 counts as an encoder
+	image.data[fakeDIOInputOffset+inputModeOffset] = 3
+	pin := SPIVariable{strVarName: char32("Counter_1")}
+	test.That(t, chip.mapNameToAddress(&pin), test.ShouldBeNil)
+	counter, err := initializeDigitalInterrupt(pin, chip, true)
+	test.That(t, err, test.ShouldBeNil)
+	enc := &revolutionPiEncoder{pin: counter}
+
+	resp, err := enc.DoCommand(context.Background(), map[string]interface{}{
+		getRawCountKey: true, getZeroOffsetKey: true, getInputModeKey: true, getDeviceInfoKey: true,
+	})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, resp[getInputModeKey].(map[string]interface{})["value"], test.ShouldEqual, 3)
+	// responses are sent over grpc, so every value must convert to protobuf
+	_, err = structpb.NewStruct(resp)
+	test.That(t, err, test.ShouldBeNil)
+}
+
+func TestDeviceStatus(t *testing.T) {
+	chip, _ := newFakeChip(t)
+	defer chip.Close()
+	m := newDeviceMonitor(chip, time.Second, chip.logger)
+	m.poll()
+	modules := m.status()
+	test.That(t, modules, test.ShouldHaveLength, 3)
+	test.That(t, modules[2].(map[string]interface{})["position"], test.ShouldEqual, 32)
+	// the status is sent over grpc, so every value must convert to protobuf
+	_, err := structpb.NewList(modules)
+	test.That(t, err, test.ShouldBeNil)
+}
+
+func TestBoardStatus(t *testing.T) {
+	b, image := newFakeBoard(t)
+	defer b.Close(context.Background())
+	image.mu.Lock()
+	image.devices[2].i16uModuleType |= piControlNotConnected
+	image.devices[2].i8uActive = 0
+	image.mu.Unlock()
+	test.That(t, b.controlChip.reloadConfiguration(), test.ShouldBeNil)
+
+	resp, err := b.DoCommand(context.Background(), map[string]interface{}{getStatusKey: true})
+	test.That(t, err, test.ShouldBeNil)
+	status := resp[getStatusKey].(map[string]interface{})
+	test.That(t, status["degraded"], test.ShouldBeTrue)
+	test.That(t, status["inactive_modules"].([]interface{})[0].(map[string]interface{})["position"], test.ShouldEqual, 31)
+	// the status is sent over grpc, so every value must convert to protobuf
+	_, err = structpb.NewStruct(resp)
+	test.That(t, err, test.ShouldBeNil)
+}
@@ -8,7 +8,6 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
-	"unsafe"
 )
 
 const (
@@ -31,6 +30,7 @@ type gpioPin struct {
 	initialized  bool
 	outputOffset uint16
 	inputOffset  uint16
+	generation   uint64 // configuration generation of the chip when the pin was initialized
 }
 
 func (pin *gpioPin) initialize() error {
@@ -42,7 +42,7 @@ func (pin *gpioPin) initialize() error {
 		// if the normal gpio output is given, use the bit position to check if we are in pwm mode.
 		// We also need to determine which address to check.
 		pwmActiveAddress := int64(pin.Address - pin.outputOffset + pin.inputOffset + outputPWMActiveOffset)
-		val, err = pin.ControlChip.getBitValue(pwmActiveAddress, pin.BitPosition)
+		val, err = pin.ControlChip.getBitValue(pwmActiveAddress, pin.BitPosition, true)
 		if err != nil {
 			return err
 		}
@@ -52,7 +52,7 @@ func (pin *gpioPin) initialize() error {
 		// Output pins start at pin.outputOffset+2, so we can subtract pin address by that amount to get the correct bit
 		pwmActiveBitPosition := uint8(pin.Address - pin.outputOffset - outputWordToPWMOffset) // between 0 and 16
 		pwmActiveAddress := int64(pin.inputOffset + outputPWMActiveOffset + uint16(pwmActiveBitPosition>>3))
-		val, err = pin.ControlChip.getBitValue(pwmActiveAddress, pwmActiveBitPosition%8)
+		val, err = pin.ControlChip.getBitValue(pwmActiveAddress, pwmActiveBitPosition%8, true)
 		if err != nil {
 			return err
 		}
@@ -65,6 +65,16 @@ func (pin *gpioPin) initialize() error {
 	return nil
 }
 
+// current returns the pin to use for a request. Pins are shared between callers and never modified, so a pin
+// initialized before the configuration was reloaded is replaced by a newly initialized one,
+// as the pin may have moved or had PWM enabled or disabled.
+func (pin *gpioPin) current() (*gpioPin, error) {
+	if pin.generation == pin.ControlChip.configGeneration.Load() {
+		return pin, nil
+	}
+	return pin.ControlChip.GetGPIOPin(pin.Name)
+}
+
 // Get the memory address to use for modifying the PWM duty cycle. This should Only be used when a PWM
 // request is made to a GPIO output pin.
 func (pin *gpioPin) getPwmAddress() uint16 {
@@ -95,23 +105,31 @@ func (pin *gpioPin) getGpioAddress() uint16 {
 
 // Set sets the state of the pin on or off.
 func (pin *gpioPin) Set(ctx context.Context, high bool, extra map[string]interface{}) error {
+	pin, err := pin.current()
+	if err != nil {
+		return err
+	}
 	if !pin.initialized {
 		return errors.New("pin not initialized")
 	}
 
-	val := uint8(0)
-	if high {
-		val = uint8(1)
+	gpioAddress, gpioBit, err := pin.getOutputBit()
+	if err != nil {
+		return err
 	}
+	return pin.ControlChip.setBitValue(gpioAddress, gpioBit, high, writeOrigin{api: "Set", name: pin.Name})
+}
 
+// getOutputBit returns the address and bit in the OutputWord used to set the pin state.
+func (pin *gpioPin) getOutputBit() (uint16, uint8, error) {
 	// Error if we are not a pin that can support GPIO Outputs
 	if !pin.isOutputPWM() && !pin.isDigitalOutput() {
-		return fmt.Errorf("cannot set pin state, Pin %s is not a digital output pin", pin.Name)
+		return 0, 0, fmt.Errorf("cannot set pin state, Pin %s is not a digital output pin", pin.Name)
 	}
 
 	// error if PWM is enabled for the pin in question
 	if pin.pwmMode {
-		return fmt.Errorf("cannot set pin state, Pin %s is configured as PWM", pin.Name)
+		return 0, 0, fmt.Errorf("cannot set pin state, Pin %s is configured as PWM", pin.Name)
 	}
 
 	gpioAddress := pin.getGpioAddress()
@@ -121,21 +139,15 @@ func (pin *gpioPin) Set(ctx context.Context, high bool, extra map[string]interfa
 	if !pin.isDigitalOutput() {
 		gpioBit = uint8(pin.Address-outputWordToPWMOffset-pin.outputOffset) % 8
 	}
-
-	// Because there could be a race in reading the byte with pin states, mutating,
-	// and writing back, we can leverage the ioctl command to modify 1 bit
-	command := SPIValue{i16uAddress: gpioAddress, i8uBit: gpioBit, i8uValue: val}
-	pin.ControlChip.logger.Debugf("Command: %#v", command)
-	//nolint:gosec
-	err := pin.ControlChip.ioCtl(uintptr(kbSetValue), unsafe.Pointer(&command))
-	if err != 0 {
-		return err
-	}
-	return nil
+	return gpioAddress, gpioBit, nil
 }
 
 // Get gets the high/low state of the pin.
 func (pin *gpioPin) Get(ctx context.Context, extra map[string]interface{}) (bool, error) {
+	pin, err := pin.current()
+	if err != nil {
+		return false, err
+	}
 	if !pin.initialized {
 		return false, errors.New("pin not initialized")
 	}
@@ -157,21 +169,25 @@ func (pin *gpioPin) Get(ctx context.Context, extra map[string]interface{}) (bool
 
 	pin.ControlChip.logger.Debugf("Reading from Address %d, bit %d", gpioAddress, gpioBit)
 
-	return pin.ControlChip.getBitValue(int64(gpioAddress), gpioBit)
+	return pin.ControlChip.getBitValue(int64(gpioAddress), gpioBit, isDirect(extra))
 }
 
 // PWM gets the pin's given duty cycle.
 func (pin *gpioPin) PWM(ctx context.Context, extra map[string]interface{}) (float64, error) {
+	pin, err := pin.current()
+	if err != nil {
+		return 0, err
+	}
 	if !pin.initialized {
 		return 0, errors.New("pin not initialized")
 	}
 	if !pin.isOutputPWM() && !pin.isDigitalOutput() {
-		return 0, fmt.Errorf("cannot get PWM, Pin %s is not a PWM pin", pin.Name)
+		return 0, fmt.Errorf("cannot get PWM, Pin %s: %w", pin.Name, ErrNotPWMPin)
 	}
 
 	// if the pin isn't configured for PWM mode, throw an error
 	if !pin.pwmMode {
-		return 0, fmt.Errorf("cannot get PWM, Pin %s is not configured for PWM", pin.Name)
+		return 0, fmt.Errorf("cannot get PWM, Pin %s is not configured for PWM: %w", pin.Name, ErrNotPWMPin)
 	}
 
 	pwmAddress := pin.Address
@@ -180,12 +196,7 @@ func (pin *gpioPin) PWM(ctx context.Context, extra map[string]interface{}) (floa
 	}
 
 	b := make([]byte, 2)
-	n, err := pin.ControlChip.fileHandle.ReadAt(b, int64(pwmAddress))
-	pin.ControlChip.logger.Debugf("Read %#d bytes", b)
-	if n != 2 {
-		return 0, fmt.Errorf("expected 2 bytes, got %#v", b)
-	}
-	if err != nil {
+	if _, err := pin.ControlChip.readAt(b, int64(pwmAddress), isDirect(extra)); err != nil {
 		return 0, err
 	}
 	b[1] = 0x00
@@ -198,26 +209,30 @@ func (pin *gpioPin) PWM(ctx context.Context, extra map[string]interface{}) (floa
 
 // SetPWM sets the pin to the given duty cycle.
 func (pin *gpioPin) SetPWM(ctx context.Context, dutyCyclePct float64, extra map[string]interface{}) error {
+	pin, err := pin.current()
+	if err != nil {
+		return err
+	}
 	if !pin.initialized {
 		return errors.New("pin not initialized")
 	}
 	if !pin.isOutputPWM() && !pin.isDigitalOutput() {
-		return fmt.Errorf("cannot set PWM, Pin %s is not a PWM pin", pin.Name)
+		return fmt.Errorf("cannot set PWM, Pin %s: %w", pin.Name, ErrNotPWMPin)
 	}
 
 	// if the pin isn't configured for PWM mode, throw an error
 	if !pin.pwmMode {
-		return fmt.Errorf("cannot set PWM, Pin %s is not configured for PWM", pin.Name)
+		return fmt.Errorf("cannot set PWM, Pin %s is not configured for PWM: %w", pin.Name, ErrNotPWMPin)
 	}
 
 	// convert from 0-1 to 0-100
 	dutyCyclePct = 100 * dutyCyclePct
 	if dutyCyclePct > 100 {
 		// Should we clamp or error?
-		return errors.New("cannot set duty cycle greater than 100%")
+		return fmt.Errorf("cannot set duty cycle greater than 100%%: %w", ErrOutOfRange)
 	}
 	if dutyCyclePct < 0 {
-		return errors.New("cannot set duty cycle less than 0%")
+		return fmt.Errorf("cannot set duty cycle less than 0%%: %w", ErrOutOfRange)
 	}
 
 	pwmAddress := pin.Address
@@ -228,27 +243,27 @@ func (pin *gpioPin) SetPWM(ctx context.Context, dutyCyclePct float64, extra map[
 	b := make([]byte, 2)
 	binary.LittleEndian.PutUint16(b, uint16(dutyCyclePct))
 	b = b[:1]
-	err := pin.ControlChip.writeValue(int64(pwmAddress), b)
+	err = pin.ControlChip.writeValue(int64(pwmAddress), b, writeOrigin{api: "SetPWM", name: pin.Name})
 	return err
 }
 
 // PWMFreq gets the PWM frequency of the pin.
 func (pin *gpioPin) PWMFreq(ctx context.Context, extra map[string]interface{}) (uint, error) {
+	pin, err := pin.current()
+	if err != nil {
+		return 0, err
+	}
 	if !pin.initialized {
 		return 0, errors.New("pin not initialized")
 	}
 	if !pin.isOutputPWM() && !pin.isDigitalOutput() {
-		return 0, fmt.Errorf("cannot get PWM Frequency, Pin %s is not a PWM pin", pin.Name)
+		return 0, fmt.Errorf("cannot get PWM Frequency, Pin %s: %w", pin.Name, ErrNotPWMPin)
 	}
 
 	b := make([]byte, 1)
 	// all PWM pins use the same PWM frequency
-	n, err := pin.ControlChip.fileHandle.ReadAt(b, int64(pin.inputOffset+outputPWMFrequencyOffset))
-	if err != nil {
-		return 0, err
-	}
-	if n != 1 {
-		return 0, errors.New("unable to read PWM Frequency")
+	if _, err := pin.ControlChip.readAt(b, int64(pin.inputOffset+outputPWMFrequencyOffset), isDirect(extra)); err != nil {
+		return 0, fmt.Errorf("unable to read PWM Frequency: %w", err)
 	}
 	pin.ControlChip.logger.Debugf("Current frequency step size: %#d", b)
 
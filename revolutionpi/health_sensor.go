@@ -0,0 +1,177 @@
+//go:build linux
+
+// Package revolutionpi implements the Revolution Pi board GPIO pins.
+package revolutionpi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.uber.org/multierr"
+	"go.viam.com/rdk/components/board"
+	"go.viam.com/rdk/components/sensor"
+	"go.viam.com/rdk/grpc"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/utils"
+)
+
+// address offsets for the inputs of the RevPi Core and Connect base modules.
+// See https://revolutionpi.com/en/tutorials/revpi-core-process-image
+const (
+	baseStatusOffset        = 0 // RevPiStatus
+	baseIOCycleOffset       = 1 // RevPiIOCycle, in ms
+	baseRS485ErrorCntOffset = 2 // RS485ErrorCnt, PiBridge communication errors
+	baseTemperatureOffset   = 4 // Core_Temperature, in degrees celsius
+	baseFrequencyOffset     = 5 // Core_Frequency, in units of 10 MHz
+)
+
+// bits of RevPiStatus.
+const (
+	statusRunning = 1 << iota
+	statusModuleExtra
+	statusModuleMissing
+	statusSizeMismatch
+	statusPiBridgeLeft
+	statusPiBridgeRight
+)
+
+// HealthModel is the model triplet for the rev-pi health sensor.
+var HealthModel = resource.NewModel("viam", "kunbus", "revolutionpi-health")
+
+// HealthConfig is the config for the rev-pi health sensor.
+type HealthConfig struct {
+	DevicePath string `json:"device_path,omitempty"`
+	// Board is the name of a rev-pi board whose active forces are included in the readings.
+	Board string `json:"board,omitempty"`
+}
+
+// revolutionPiHealth decodes the status of the base module and the PiBridge into named readings.
+type revolutionPiHealth struct {
+	resource.Named
+	resource.AlwaysRebuild
+	chip *gpioChip
+	base SDeviceInfo
+	pins []SPIVariable
+	// the board whose forces are reported, nil if none is configured
+	board resource.Resource
+}
+
+func init() {
+	resource.RegisterComponent(
+		sensor.API,
+		HealthModel,
+		resource.Registration[sensor.Sensor, *HealthConfig]{Constructor: newHealthSensor})
+}
+
+// Validate validates the HealthConfig.
+func (cfg *HealthConfig) Validate(path string) ([]string, error) {
+	if err := validateDevicePath(cfg.DevicePath); err != nil {
+		return nil, utils.NewConfigValidationError(path, err)
+	}
+	if cfg.Board != "" {
+		return []string{cfg.Board}, nil
+	}
+	return []string{}, nil
+}
+
+func newHealthSensor(
+	ctx context.Context,
+	deps resource.Dependencies,
+	conf resource.Config,
+	logger logging.Logger,
+) (sensor.Sensor, error) {
+	svcConfig, err := resource.NativeConfig[*HealthConfig](conf)
+	if err != nil {
+		return nil, err
+	}
+	var forcesBoard resource.Resource
+	if svcConfig.Board != "" {
+		forcesBoard, err = board.FromDependencies(deps, svcConfig.Board)
+		if err != nil {
+			return nil, err
+		}
+	}
+	// the health sensor reports missing and failed modules, so it must not require every module to be present
+	chip, _, err := openGpioChip(svcConfig.DevicePath, logger)
+	if err != nil {
+		return nil, err
+	}
+	h, err := newRevolutionPiHealth(conf.ResourceName(), chip, forcesBoard)
+	if err != nil {
+		return nil, multierr.Combine(err, chip.Close())
+	}
+	return h, nil
+}
+
+// newRevolutionPiHealth reads the health of the base module of chip.
+func newRevolutionPiHealth(name resource.Name, chip *gpioChip, forcesBoard resource.Resource) (*revolutionPiHealth, error) {
+	base := chip.getBaseDevice()
+	if base == nil {
+		return nil, errors.New("unable to find the RevPi base module")
+	}
+
+	// the base module variables can be renamed in PiCtory, so address them by their offsets
+	inputOffset := base.i16uInputOffset
+	pins := []SPIVariable{
+		{strVarName: char32("RevPiStatus"), i16uAddress: inputOffset + baseStatusOffset, i16uLength: 8},
+		{strVarName: char32("RevPiIOCycle"), i16uAddress: inputOffset + baseIOCycleOffset, i16uLength: 8},
+		{strVarName: char32("RS485ErrorCnt"), i16uAddress: inputOffset + baseRS485ErrorCntOffset, i16uLength: 16},
+		{strVarName: char32("Core_Temperature"), i16uAddress: inputOffset + baseTemperatureOffset, i16uLength: 8},
+		{strVarName: char32("Core_Frequency"), i16uAddress: inputOffset + baseFrequencyOffset, i16uLength: 8},
+	}
+
+	return &revolutionPiHealth{Named: name.AsNamed(), chip: chip, base: *base, pins: pins, board: forcesBoard}, nil
+}
+
+// Readings returns the decoded RevPiStatus bits, the core temperature, the CPU frequency, the IO cycle time
+// and the PiBridge error counter. When a board is configured, its forced variables are included.
+func (h *revolutionPiHealth) Readings(ctx context.Context, extra map[string]interface{}) (map[string]interface{}, error) {
+	values, _, err := h.chip.readVariables(h.pins, false)
+	if err != nil {
+		return nil, err
+	}
+	// the values are converted to int, as readings cannot carry 8 and 16 bit integers
+	status := int(values[0].(uint32))
+	readings := map[string]interface{}{
+		"status":               status,
+		"running":              status&statusRunning != 0,
+		"module_extra":         status&statusModuleExtra != 0,
+		"module_missing":       status&statusModuleMissing != 0,
+		"module_size_mismatch": status&statusSizeMismatch != 0,
+		"pibridge_left":        status&statusPiBridgeLeft != 0,
+		"pibridge_right":       status&statusPiBridgeRight != 0,
+		"io_cycle_ms":          int(values[1].(uint32)),
+		"pibridge_error_count": int(values[2].(uint32)),
+		"core_temperature_c":   int(values[3].(uint32)),
+		"core_frequency_mhz":   int(values[4].(uint32)) * 10,
+		"base_module":          getModuleName(h.base.i16uModuleType),
+		"base_module_serial":   int(h.base.i32uSerialnumber),
+	}
+	if h.board != nil {
+		// the board may run in another process, so its forces are listed through DoCommand
+		resp, err := h.board.DoCommand(ctx, map[string]interface{}{listForcesKey: true})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list the forces of the board: %w", err)
+		}
+		forces, _ := resp[listForcesKey].([]interface{})
+		names := make([]interface{}, 0, len(forces))
+		for _, f := range forces {
+			if forced, ok := f.(map[string]interface{}); ok {
+				names = append(names, forced["name"])
+			}
+		}
+		readings["forced"] = len(names) > 0
+		readings["forced_variables"] = names
+	}
+	return readings, nil
+}
+
+func (h *revolutionPiHealth) DoCommand(ctx context.Context, req map[string]interface{}) (map[string]interface{}, error) {
+	return nil, grpc.UnimplementedError
+}
+
+func (h *revolutionPiHealth) Close(ctx context.Context) error {
+	return h.chip.Close()
+}
@@ -0,0 +1,204 @@
+//go:build linux
+
+// Package revolutionpi implements the Revolution Pi.
+package revolutionpi
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// InterlockConfig is a rule that rejects writes that would set Write.Variable to a value matching Write while
+// every condition in When holds, e.g. never energize a motor's forward output while its reverse output is on.
+type InterlockConfig struct {
+	Name  string               `json:"name"`
+	Write InterlockCondition   `json:"write"`
+	When  []InterlockCondition `json:"when"`
+}
+
+// InterlockCondition compares a variable with a value. Is compares bit variables, while Above and Below compare
+// numeric variables, matching values between them when both are set.
+type InterlockCondition struct {
+	Variable string   `json:"variable"`
+	Is       *bool    `json:"is,omitempty"`
+	Above    *float64 `json:"above,omitempty"`
+	Below    *float64 `json:"below,omitempty"`
+}
+
+// Validate checks that the rule is named and has a write and at least one condition.
+func (cfg InterlockConfig) Validate() error {
+	if cfg.Name == "" {
+		return errors.New("interlocks must have a name")
+	}
+	if err := cfg.Write.Validate(); err != nil {
+		return fmt.Errorf("invalid write of interlock %q: %w", cfg.Name, err)
+	}
+	if len(cfg.When) == 0 {
+		return fmt.Errorf("interlock %q must have at least one condition in when", cfg.Name)
+	}
+	for _, cond := range cfg.When {
+		if err := cond.Validate(); err != nil {
+			return fmt.Errorf("invalid condition of interlock %q: %w", cfg.Name, err)
+		}
+	}
+	return nil
+}
+
+// Validate checks that the condition names a variable and compares it either with a bool or with numbers.
+func (cond InterlockCondition) Validate() error {
+	if cond.Variable == "" {
+		return errors.New("conditions must name a variable")
+	}
+	numeric := cond.Above != nil || cond.Below != nil
+	if cond.Is == nil && !numeric {
+		return fmt.Errorf("condition on %s must set is, above or below", cond.Variable)
+	}
+	if cond.Is != nil && numeric {
+		return fmt.Errorf("condition on %s cannot set is along with above or below", cond.Variable)
+	}
+	return nil
+}
+
+// matches checks whether a decoded value matches the condition.
+func (cond InterlockCondition) matches(value interface{}) (bool, error) {
+	if cond.Is != nil {
+		high, ok := value.(bool)
+		if !ok {
+			num, isNum := numericValue(value)
+			if !isNum {
+				return false, fmt.Errorf("%s is %v, which cannot be compared with %v", cond.Variable, value, *cond.Is)
+			}
+			high = num != 0
+		}
+		return high == *cond.Is, nil
+	}
+	num, ok := numericValue(value)
+	if !ok {
+		return false, fmt.Errorf("%s is %v, which is not a number", cond.Variable, value)
+	}
+	return (cond.Above == nil || num > *cond.Above) && (cond.Below == nil || num < *cond.Below), nil
+}
+
+func (cond InterlockCondition) String() string {
+	var parts []string
+	if cond.Is != nil {
+		parts = append(parts, fmt.Sprintf("is %v", *cond.Is))
+	}
+	if cond.Above != nil {
+		parts = append(parts, fmt.Sprintf("above %v", *cond.Above))
+	}
+	if cond.Below != nil {
+		parts = append(parts, fmt.Sprintf("below %v", *cond.Below))
+	}
+	return cond.Variable + " " + strings.Join(parts, " and ")
+}
+
+// interlocks checks the interlock rules of the board on every write of its chip.
+type interlocks struct {
+	rules []InterlockConfig
+
+	// the rules resolved to variables, for the configuration generation they were resolved for
+	mu         sync.Mutex
+	resolved   bool
+	generation uint64
+	checks     []interlockCheck
+}
+
+// interlockCheck is an interlock rule resolved to the variables of its conditions.
+type interlockCheck struct {
+	rule  InterlockConfig
+	write SPIVariable
+	mask  []byte // the bits of the written variable in the bytes starting at its address
+	when  []SPIVariable
+	// why the conditions of the rule cannot be checked, which rejects every write the rule applies to
+	err error
+}
+
+func newInterlocks(rules []InterlockConfig) *interlocks {
+	return &interlocks{rules: rules}
+}
+
+// interlockChecks resolves the interlock rules to their variables, again whenever the configuration is reloaded.
+func (g *gpioChip) interlockChecks() []interlockCheck {
+	il := g.interlocks
+	il.mu.Lock()
+	defer il.mu.Unlock()
+	generation := g.configGeneration.Load()
+	if il.resolved && il.generation == generation {
+		return il.checks
+	}
+
+	checks := make([]interlockCheck, 0, len(il.rules))
+	for _, rule := range il.rules {
+		check := interlockCheck{rule: rule, write: SPIVariable{strVarName: char32(rule.Write.Variable)}}
+		if err := g.mapNameToAddress(&check.write); err != nil {
+			g.logger.Warnf("ignoring interlock %q: %v", rule.Name, err)
+			continue
+		}
+		check.mask = variableMask(check.write)
+		for _, cond := range rule.When {
+			pin := SPIVariable{strVarName: char32(cond.Variable)}
+			if err := g.mapNameToAddress(&pin); err != nil {
+				// writes the rule protects are rejected rather than allowed unchecked
+				g.logger.Warnf("interlock %q cannot be checked, writes to %s will be rejected: %v", rule.Name, rule.Write.Variable, err)
+				check.err = err
+				break
+			}
+			check.when = append(check.when, pin)
+		}
+		checks = append(checks, check)
+	}
+	il.checks, il.generation, il.resolved = checks, generation, true
+	return checks
+}
+
+// interlockError explains which interlock rule a write of the bits in mask of b at address violates, or returns nil
+// if it violates none. Conditions on variables written by the same write use their written values.
+func (g *gpioChip) interlockError(address int64, b, mask []byte) error {
+	for _, check := range g.interlockChecks() {
+		if !masksOverlap(check.write.i16uAddress, check.mask, address, mask) {
+			continue
+		}
+		if check.err != nil {
+			return fmt.Errorf("interlock %q cannot be checked: %w", check.rule.Name, check.err)
+		}
+		value, err := g.writtenValue(check.write, address, b, mask)
+		if err != nil {
+			return fmt.Errorf("interlock %q cannot be checked: %w", check.rule.Name, err)
+		}
+		matched, err := check.rule.Write.matches(value)
+		if err != nil {
+			return fmt.Errorf("interlock %q cannot be checked: %w", check.rule.Name, err)
+		}
+		if !matched {
+			continue
+		}
+
+		violated := true
+		for i, pin := range check.when {
+			value, err := g.writtenValue(pin, address, b, mask)
+			if err != nil {
+				return fmt.Errorf("interlock %q cannot be checked: %w", check.rule.Name, err)
+			}
+			matched, err := check.rule.When[i].matches(value)
+			if err != nil {
+				return fmt.Errorf("interlock %q cannot be checked: %w", check.rule.Name, err)
+			}
+			if !matched {
+				violated = false
+				break
+			}
+		}
+		if violated {
+			when := make([]string, 0, len(check.rule.When))
+			for _, cond := range check.rule.When {
+				when = append(when, cond.String())
+			}
+			return fmt.Errorf("interlock %q: writing %v to %s is not allowed while %s",
+				check.rule.Name, value, check.rule.Write.Variable, strings.Join(when, " and "))
+		}
+	}
+	return nil
+}
@@ -3,6 +3,8 @@
 // Package revolutionpi implements the Revolution Pi.
 package revolutionpi
 
+import "fmt"
+
 func ioctlAddress(v int) int {
 	kbIocMagic := int('K')
 	magic := (((0) << (((0 + 8) + 8) + 14)) | ((kbIocMagic) << (0 + 8)) | ((v) << 0) | ((0) << ((0 + 8) + 8)))
@@ -92,6 +94,28 @@ func (dev *SDeviceInfo) isAIO() bool {
 	return dev.i16uModuleType == 103
 }
 
+// isBase checks whether the module is the base module, such as a RevPi Core or Connect, which is always at address 0.
+func (dev *SDeviceInfo) isBase() bool {
+	return dev.i8uAddress == 0
+}
+
+// configurationError returns why a device from the device list at index i cannot be used, or nil if it is active.
+func (dev *SDeviceInfo) configurationError(i int) error {
+	if dev.i8uActive != 0 {
+		return nil
+	}
+	if dev.isConnected() {
+		return fmt.Errorf("device %d is type %s but is not configured: %w", i, getModuleName(dev.i16uModuleType), ErrModuleNotConfigured)
+	}
+	return fmt.Errorf("device %d is not connected", i)
+}
+
+// isConnected checks whether the module is physically present. Configured modules that are missing
+// are reported with the piControlNotConnected bit set in their module type.
+func (dev *SDeviceInfo) isConnected() bool {
+	return dev.i16uModuleType&piControlNotConnected != piControlNotConnected
+}
+
 // getModuleName gets the module name based on the module type.
 func getModuleName(moduleType uint16) string {
 	switch {
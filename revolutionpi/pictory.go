@@ -0,0 +1,149 @@
+//go:build linux
+
+// Package revolutionpi implements the Revolution Pi.
+package revolutionpi
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// defaultPiCtoryConfigPath is where PiCtory saves the Start-Config loaded by piControl.
+const defaultPiCtoryConfigPath = "/etc/revpi/config.rsc"
+
+// piCtoryConfigPathFor returns the PiCtory configuration of the process image at devPath. PiCtory only configures
+// the default device node, so other device nodes have no known configuration and an empty path is returned.
+func piCtoryConfigPathFor(devPath string) string {
+	if devPath == "" || filepath.Clean(devPath) == defaultDevicePath {
+		return defaultPiCtoryConfigPath
+	}
+	return ""
+}
+
+// piCtoryConfig is the subset of a PiCtory config.rsc file used by the module.
+type piCtoryConfig struct {
+	Devices []piCtoryDevice `json:"Devices"`
+}
+
+// piCtoryDevice is a module configured in PiCtory.
+type piCtoryDevice struct {
+	Name        string                   `json:"name"`
+	ProductType flexInt                  `json:"productType"`
+	Position    flexInt                  `json:"position"`
+	Offset      flexInt                  `json:"offset"`
+	Inputs      map[string][]interface{} `json:"inp"`
+	Outputs     map[string][]interface{} `json:"out"`
+	Memory      map[string][]interface{} `json:"mem"`
+}
+
+// piCtoryVariable is a variable of a module configured in PiCtory.
+type piCtoryVariable struct {
+	Name        string
+	Section     string // "inp", "out" or "mem"
+	Address     uint16 // address of the byte in the process image
+	Bit         uint8  // 0-7 bit position for bit variables
+	Length      uint16 // length of the variable in bits
+	DeviceName  string
+	ProductType uint16
+	Position    int
+	// offset of the variable from the start of its module
+	deviceOffset uint16
+}
+
+// flexInt decodes numbers that PiCtory saves either as json numbers or as strings.
+type flexInt int
+
+func (f *flexInt) UnmarshalJSON(data []byte) error {
+	str := strings.Trim(string(data), `"`)
+	if str == "" {
+		*f = 0
+		return nil
+	}
+	val, err := strconv.Atoi(str)
+	if err != nil {
+		return fmt.Errorf("expected a number, got %s", data)
+	}
+	*f = flexInt(val)
+	return nil
+}
+
+// loadPiCtoryConfig reads and parses a PiCtory config.rsc file.
+func loadPiCtoryConfig(path string) (*piCtoryConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var conf piCtoryConfig
+	if err := json.Unmarshal(data, &conf); err != nil {
+		return nil, fmt.Errorf("failed to parse PiCtory configuration %s: %w", path, err)
+	}
+	return &conf, nil
+}
+
+// variables returns every variable defined in the configuration, keyed by name.
+func (conf *piCtoryConfig) variables() map[string]piCtoryVariable {
+	vars := map[string]piCtoryVariable{}
+	for _, dev := range conf.Devices {
+		for section, entries := range map[string]map[string][]interface{}{"inp": dev.Inputs, "out": dev.Outputs, "mem": dev.Memory} {
+			for _, entry := range entries {
+				variable, ok := parsePiCtoryEntry(entry)
+				if !ok {
+					continue
+				}
+				variable.Section = section
+				variable.Address += uint16(dev.Offset)
+				variable.DeviceName = dev.Name
+				variable.ProductType = uint16(dev.ProductType)
+				variable.Position = int(dev.Position)
+				vars[variable.Name] = variable
+			}
+		}
+	}
+	return vars
+}
+
+// parsePiCtoryEntry parses a variable entry of a device. Entries are saved as
+// [name, default value, length in bits, offset in the module, exported, sort order, comment, bit position].
+func parsePiCtoryEntry(entry []interface{}) (piCtoryVariable, bool) {
+	if len(entry) < 4 {
+		return piCtoryVariable{}, false
+	}
+	name, ok := entry[0].(string)
+	if !ok || name == "" {
+		return piCtoryVariable{}, false
+	}
+	length, err := entryInt(entry[2])
+	if err != nil {
+		return piCtoryVariable{}, false
+	}
+	offset, err := entryInt(entry[3])
+	if err != nil {
+		return piCtoryVariable{}, false
+	}
+	bit := 0
+	if len(entry) > 7 {
+		// bit positions are only saved for bit variables
+		bit, _ = entryInt(entry[7])
+	}
+	return piCtoryVariable{
+		Name: name, Address: uint16(offset), Bit: uint8(bit), Length: uint16(length), deviceOffset: uint16(offset),
+	}, true
+}
+
+func entryInt(val interface{}) (int, error) {
+	switch v := val.(type) {
+	case float64:
+		return int(v), nil
+	case string:
+		if v == "" {
+			return 0, nil
+		}
+		return strconv.Atoi(v)
+	default:
+		return 0, fmt.Errorf("unexpected value %v", val)
+	}
+}
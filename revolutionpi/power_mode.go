@@ -0,0 +1,173 @@
+//go:build linux
+
+// Package revolutionpi implements the Revolution Pi.
+package revolutionpi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+	"unsafe"
+
+	pb "go.viam.com/api/component/board/v1"
+	"go.viam.com/utils"
+)
+
+// arguments of kbStopIO.
+const (
+	kbStopIOStart int32 = 0
+	kbStopIOStop  int32 = 1
+)
+
+// how long safe outputs are given to reach the modules before IO is stopped, a few piControl cycles.
+const safeOutputsSettleTime = 100 * time.Millisecond
+
+// powerState tracks whether the board stopped IO, and when it is scheduled to resume.
+type powerState struct {
+	ioStopped    bool
+	resumeAt     time.Time
+	cancelResume func()
+}
+
+// SetPowerMode stops the exchange of the process image with the modules for POWER_MODE_OFFLINE_DEEP, after clearing
+// any forces and writing the configured safe outputs, and resumes it for POWER_MODE_NORMAL. If a duration is given with
+// POWER_MODE_OFFLINE_DEEP, IO resumes automatically once it elapses.
+func (b *revolutionPiBoard) SetPowerMode(ctx context.Context, mode pb.PowerMode, duration *time.Duration) error {
+	b.powerMu.Lock()
+	defer b.powerMu.Unlock()
+	if b.cancelCtx.Err() != nil {
+		return ErrBoardClosed
+	}
+
+	switch mode {
+	case pb.PowerMode_POWER_MODE_OFFLINE_DEEP:
+		if duration != nil && *duration <= 0 {
+			return errors.New("power mode duration must be positive")
+		}
+		if !b.power.ioStopped {
+			// forces would hold outputs at their forced values rather than the safe ones
+			if _, err := b.controlChip.clearForces(nil, "IO is being stopped"); err != nil {
+				return fmt.Errorf("failed to clear forces, IO was not stopped: %w", err)
+			}
+			if err := b.writeSafeOutputs(ctx); err != nil {
+				return fmt.Errorf("failed to write safe outputs, IO was not stopped: %w", err)
+			}
+		}
+		if err := b.controlChip.setIOStopped(true); err != nil {
+			return err
+		}
+		b.power.ioStopped = true
+		b.scheduleResume(duration)
+		if duration != nil {
+			b.logger.Warnf("stopped process image IO for %v", *duration)
+		} else {
+			b.logger.Warn("stopped process image IO")
+		}
+		return nil
+	case pb.PowerMode_POWER_MODE_NORMAL:
+		if duration != nil {
+			return fmt.Errorf("a duration can only be used with %v", pb.PowerMode_POWER_MODE_OFFLINE_DEEP)
+		}
+		b.scheduleResume(nil)
+		return b.resumeIO()
+	default:
+		return fmt.Errorf("unsupported power mode %v", mode)
+	}
+}
+
+// writeSafeOutputs writes the configured safe outputs and waits for them to reach the modules.
+func (b *revolutionPiBoard) writeSafeOutputs(ctx context.Context) error {
+	if len(b.safeOutputs) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(b.safeOutputs))
+	for name := range b.safeOutputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := b.writeParameter(name, b.safeOutputs[name], "SetPowerMode"); err != nil {
+			return err
+		}
+	}
+	if !utils.SelectContextOrWait(ctx, safeOutputsSettleTime) {
+		return ctx.Err()
+	}
+	return nil
+}
+
+// scheduleResume replaces any scheduled resume of IO with one after duration, or cancels it if duration is nil.
+// It must be called with powerMu held.
+func (b *revolutionPiBoard) scheduleResume(duration *time.Duration) {
+	if b.power.cancelResume != nil {
+		b.power.cancelResume()
+		b.power.cancelResume = nil
+		b.power.resumeAt = time.Time{}
+	}
+	if duration == nil {
+		return
+	}
+
+	resumeCtx, cancelResume := context.WithCancel(b.cancelCtx)
+	b.power.cancelResume = cancelResume
+	b.power.resumeAt = time.Now().Add(*duration)
+	wait := *duration
+	b.activeBackgroundWorkers.Add(1)
+	utils.ManagedGo(func() {
+		if !utils.SelectContextOrWait(resumeCtx, wait) {
+			return
+		}
+		b.powerMu.Lock()
+		defer b.powerMu.Unlock()
+		// the resume may have been replaced while waiting for the lock
+		if resumeCtx.Err() != nil {
+			return
+		}
+		b.power.cancelResume = nil
+		b.power.resumeAt = time.Time{}
+		if err := b.resumeIO(); err != nil {
+			b.logger.Errorf("failed to resume process image IO: %v", err)
+		}
+	}, b.activeBackgroundWorkers.Done)
+}
+
+// resumeIO resumes the exchange of the process image with the modules. It must be called with powerMu held.
+func (b *revolutionPiBoard) resumeIO() error {
+	if err := b.controlChip.setIOStopped(false); err != nil {
+		return err
+	}
+	if b.power.ioStopped {
+		b.logger.Info("resumed process image IO")
+	}
+	b.power.ioStopped = false
+	return nil
+}
+
+// powerStatus reports whether the board stopped IO and when it is scheduled to resume.
+func (b *revolutionPiBoard) powerStatus() map[string]interface{} {
+	b.powerMu.Lock()
+	defer b.powerMu.Unlock()
+	status := map[string]interface{}{"io_stopped": b.power.ioStopped}
+	if !b.power.resumeAt.IsZero() {
+		status["io_resume_at"] = b.power.resumeAt.Format(time.RFC3339)
+	}
+	return status
+}
+
+// setIOStopped stops or resumes the exchange of the process image with the modules.
+func (g *gpioChip) setIOStopped(stop bool) error {
+	arg := kbStopIOStart
+	if stop {
+		arg = kbStopIOStop
+	}
+	//nolint:gosec
+	if err := g.ioCtl(uintptr(kbStopIO), unsafe.Pointer(&arg)); err != nil {
+		if stop {
+			return fmt.Errorf("failed to stop IO: %w", err)
+		}
+		return fmt.Errorf("failed to resume IO: %w", err)
+	}
+	return nil
+}
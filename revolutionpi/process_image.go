@@ -0,0 +1,60 @@
+//go:build linux
+
+// Package revolutionpi implements the Revolution Pi.
+package revolutionpi
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// defaultDevicePath is the device node of the process image on a RevPi with a single PiBridge.
+const defaultDevicePath = "/dev/piControl0"
+
+// processImage is the piControl process image read and written by the chip. It is an interface so that
+// the chip can be used with other device nodes, or a test double.
+type processImage interface {
+	io.ReaderAt
+	io.WriterAt
+	io.Closer
+	// ioctl sends a piControl command with the given message, returning the results of the syscall.
+	ioctl(command uintptr, message unsafe.Pointer) (uintptr, uintptr, syscall.Errno)
+}
+
+// piControlDevice is a process image exposed by the piControl driver as a device node.
+type piControlDevice struct {
+	*os.File
+}
+
+// openPiControlDevice opens the process image at path, or at the default device node if path is empty.
+func openPiControlDevice(path string) (*piControlDevice, error) {
+	if path == "" {
+		path = defaultDevicePath
+	}
+	devPath := filepath.Clean(path)
+	fd, err := os.OpenFile(devPath, os.O_RDWR, fs.FileMode(os.O_RDWR))
+	if err != nil {
+		return nil, fmt.Errorf("open chip %v failed: %w", devPath, err)
+	}
+	return &piControlDevice{File: fd}, nil
+}
+
+func (d *piControlDevice) ioctl(command uintptr, message unsafe.Pointer) (uintptr, uintptr, syscall.Errno) {
+	return unix.Syscall(unix.SYS_IOCTL, d.Fd(), command, uintptr(message))
+}
+
+// validateDevicePath checks that a configured device path is absolute. An empty path uses the default device node.
+func validateDevicePath(devicePath string) error {
+	if devicePath != "" && !filepath.IsAbs(devicePath) {
+		return errors.New("device_path must be an absolute path")
+	}
+	return nil
+}
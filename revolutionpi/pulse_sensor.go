@@ -0,0 +1,221 @@
+//go:build linux
+
+// Package revolutionpi implements the Revolution Pi board GPIO pins.
+package revolutionpi
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.uber.org/multierr"
+	"go.viam.com/rdk/components/sensor"
+	"go.viam.com/rdk/grpc"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/utils"
+)
+
+const (
+	defaultPulseWindow         = time.Second
+	defaultPulseSampleInterval = 100 * time.Millisecond
+)
+
+// PulseSensorModel is the model triplet for the rev-pi pulse sensor.
+var PulseSensorModel = resource.NewModel("viam", "kunbus", "revolutionpi-pulse-sensor")
+
+// PulseSensorConfig is the config for the rev-pi pulse sensor.
+type PulseSensorConfig struct {
+	Name             string  `json:"pin_name"`
+	PulsesPerUnit    float64 `json:"pulses_per_unit,omitempty"`
+	WindowSec        float64 `json:"window_sec,omitempty"`
+	SampleIntervalMs int     `json:"sample_interval_ms,omitempty"`
+	DevicePath       string  `json:"device_path,omitempty"`
+}
+
+// revolutionPiPulseSensor turns a DIO counter into a rate and running total, such as a flow meter or RPM sensor.
+type revolutionPiPulseSensor struct {
+	resource.Named
+	resource.AlwaysRebuild
+	logger         logging.Logger
+	pin            *counterPin
+	pulsesPerUnit  float64
+	window         time.Duration
+	sampleInterval time.Duration
+
+	mu          sync.Mutex
+	samples     []pulseSample // samples within the window, oldest first
+	lastCount   uint32
+	totalPulses uint64
+	resets      int
+	instantRate float64 // pulses per second between the two most recent samples
+
+	cancelCtx               context.Context
+	cancelFunc              func()
+	activeBackgroundWorkers sync.WaitGroup
+}
+
+type pulseSample struct {
+	time        time.Time
+	totalPulses uint64
+}
+
+func init() {
+	resource.RegisterComponent(
+		sensor.API,
+		PulseSensorModel,
+		resource.Registration[sensor.Sensor, *PulseSensorConfig]{Constructor: newPulseSensor})
+}
+
+// Validate validates the PulseSensorConfig.
+func (cfg *PulseSensorConfig) Validate(path string) ([]string, error) {
+	if cfg.Name == "" {
+		return nil, utils.NewConfigValidationFieldRequiredError(path, "pin_name")
+	}
+	if cfg.PulsesPerUnit < 0 {
+		return nil, utils.NewConfigValidationError(path, errors.New("pulses_per_unit cannot be negative"))
+	}
+	if cfg.WindowSec < 0 {
+		return nil, utils.NewConfigValidationError(path, errors.New("window_sec cannot be negative"))
+	}
+	if cfg.SampleIntervalMs < 0 {
+		return nil, utils.NewConfigValidationError(path, errors.New("sample_interval_ms cannot be negative"))
+	}
+	if err := validateDevicePath(cfg.DevicePath); err != nil {
+		return nil, utils.NewConfigValidationError(path, err)
+	}
+	return []string{}, nil
+}
+
+func newPulseSensor(
+	ctx context.Context,
+	_ resource.Dependencies,
+	conf resource.Config,
+	logger logging.Logger,
+) (sensor.Sensor, error) {
+	svcConfig, err := resource.NativeConfig[*PulseSensorConfig](conf)
+	if err != nil {
+		return nil, err
+	}
+	chip, err := newGpioChip(svcConfig.DevicePath, logger)
+	if err != nil {
+		return nil, err
+	}
+	pin := SPIVariable{strVarName: char32(svcConfig.Name)}
+	err = chip.mapNameToAddress(&pin)
+	if err != nil {
+		return nil, multierr.Combine(err, chip.Close())
+	}
+	counter, err := initializeDigitalInterrupt(pin, chip, false)
+	if err != nil {
+		return nil, multierr.Combine(err, chip.Close())
+	}
+	count, err := counter.Value()
+	if err != nil {
+		return nil, multierr.Combine(err, chip.Close())
+	}
+
+	s := &revolutionPiPulseSensor{
+		Named:          conf.ResourceName().AsNamed(),
+		logger:         logger,
+		pin:            counter,
+		pulsesPerUnit:  svcConfig.PulsesPerUnit,
+		window:         time.Duration(svcConfig.WindowSec * float64(time.Second)),
+		sampleInterval: time.Duration(svcConfig.SampleIntervalMs) * time.Millisecond,
+		lastCount:      count,
+		samples:        []pulseSample{{time: time.Now()}},
+	}
+	if s.pulsesPerUnit == 0 {
+		s.pulsesPerUnit = 1
+	}
+	if s.window == 0 {
+		s.window = defaultPulseWindow
+	}
+	if s.sampleInterval == 0 {
+		s.sampleInterval = defaultPulseSampleInterval
+	}
+
+	s.cancelCtx, s.cancelFunc = context.WithCancel(context.Background())
+	s.activeBackgroundWorkers.Add(1)
+	utils.ManagedGo(s.sampleCounter, s.activeBackgroundWorkers.Done)
+	return s, nil
+}
+
+// sampleCounter polls the hardware counter and accumulates the pulses seen until the sensor is closed.
+func (s *revolutionPiPulseSensor) sampleCounter() {
+	ticker := time.NewTicker(s.sampleInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.cancelCtx.Done():
+			return
+		case now := <-ticker.C:
+			count, err := s.pin.Value()
+			if err != nil {
+				s.logger.Errorf("failed to read counter %s: %v", s.pin.pinName, err)
+				continue
+			}
+			s.addSample(now, count)
+		}
+	}
+}
+
+func (s *revolutionPiPulseSensor) addSample(now time.Time, count uint32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delta, reset := counterDelta(s.lastCount, count)
+	if reset {
+		s.resets++
+		s.logger.Infof("counter %s was reset, last count %d, current count %d", s.pin.pinName, s.lastCount, count)
+	}
+	s.lastCount = count
+	s.totalPulses += uint64(delta)
+
+	last := s.samples[len(s.samples)-1]
+	if elapsed := now.Sub(last.time).Seconds(); elapsed > 0 {
+		s.instantRate = float64(delta) / elapsed
+	}
+	s.samples = append(s.samples, pulseSample{time: now, totalPulses: s.totalPulses})
+
+	// keep one sample at or before the start of the window so the average covers the whole window
+	cutoff := now.Add(-s.window)
+	drop := 0
+	for drop < len(s.samples)-1 && !s.samples[drop+1].time.After(cutoff) {
+		drop++
+	}
+	s.samples = s.samples[drop:]
+}
+
+// Readings returns the instantaneous rate, the average rate over the window and the running total in units.
+// Rates are reported in units per second.
+func (s *revolutionPiPulseSensor) Readings(ctx context.Context, extra map[string]interface{}) (map[string]interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	oldest := s.samples[0]
+	newest := s.samples[len(s.samples)-1]
+	averageRate := 0.0
+	if elapsed := newest.time.Sub(oldest.time).Seconds(); elapsed > 0 {
+		averageRate = float64(newest.totalPulses-oldest.totalPulses) / elapsed
+	}
+
+	return map[string]interface{}{
+		"rate":           s.instantRate / s.pulsesPerUnit,
+		"average_rate":   averageRate / s.pulsesPerUnit,
+		"total":          float64(s.totalPulses) / s.pulsesPerUnit,
+		"pulses":         s.totalPulses,
+		"counter_resets": s.resets,
+	}, nil
+}
+
+func (s *revolutionPiPulseSensor) DoCommand(ctx context.Context, req map[string]interface{}) (map[string]interface{}, error) {
+	return nil, grpc.UnimplementedError
+}
+
+func (s *revolutionPiPulseSensor) Close(ctx context.Context) error {
+	s.cancelFunc()
+	s.activeBackgroundWorkers.Wait()
+	return s.pin.controlChip.Close()
+}
@@ -0,0 +1,189 @@
+//go:build linux
+
+// Package revolutionpi implements the Revolution Pi.
+package revolutionpi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"go.viam.com/utils"
+)
+
+// pulseTrain toggles an output on and off until it has been on count times, or until it is stopped if count is 0.
+type pulseTrain struct {
+	address   uint16
+	bit       uint8
+	on, off   time.Duration
+	count     int
+	revert    bool // the value of the output before the train started
+	completed atomic.Int64
+	cancel    func()
+}
+
+// ioCycle reads the duration of a piControl IO cycle from RevPiIOCycle of the base module.
+func (g *gpioChip) ioCycle() (time.Duration, error) {
+	base := g.getBaseDevice()
+	if base == nil {
+		return 0, errors.New("unable to find the RevPi base module to read the IO cycle time")
+	}
+	buf := make([]byte, 1)
+	if _, err := g.readAt(buf, int64(base.i16uInputOffset+baseIOCycleOffset), true); err != nil {
+		return 0, fmt.Errorf("failed to read the IO cycle time: %w", err)
+	}
+	// cycles shorter than a millisecond are reported as 0
+	return time.Duration(max(buf[0], 1)) * time.Millisecond, nil
+}
+
+// startPulseTrain toggles a digital output on for on and off for off, count times or until it is stopped if count
+// is 0. Both times must be at least one IO cycle, as shorter changes may never reach the module. A train replaces any
+// pulse or train of the output, and when it ends the output reverts to the value it had before.
+func (b *revolutionPiBoard) startPulseTrain(name string, on, off time.Duration, count int) error {
+	if count < 0 {
+		return errors.New("pulse count cannot be negative")
+	}
+	cycle, err := b.controlChip.ioCycle()
+	if err != nil {
+		return err
+	}
+	if on < cycle || off < cycle {
+		return fmt.Errorf("%w: on and off times must be at least one IO cycle (%v), got %v and %v", ErrOutOfRange, cycle, on, off)
+	}
+	pin, err := b.controlChip.GetGPIOPin(name)
+	if err != nil {
+		return err
+	}
+	address, bit, err := pin.getOutputBit()
+	if err != nil {
+		return err
+	}
+
+	b.pulsesMu.Lock()
+	defer b.pulsesMu.Unlock()
+	if b.cancelCtx.Err() != nil {
+		return ErrBoardClosed
+	}
+	var revert bool
+	if p, ok := b.pulses[name]; ok {
+		revert = p.revert
+		p.cancel()
+		delete(b.pulses, name)
+	} else if previous, ok := b.pulseTrains[name]; ok {
+		revert = previous.revert
+		previous.cancel()
+		delete(b.pulseTrains, name)
+	} else if revert, err = b.controlChip.getBitValue(int64(address), bit, true); err != nil {
+		return err
+	}
+
+	trainCtx, cancel := context.WithCancel(b.cancelCtx)
+	train := &pulseTrain{address: address, bit: bit, on: on, off: off, count: count, revert: revert, cancel: cancel}
+	if b.pulseTrains == nil {
+		b.pulseTrains = map[string]*pulseTrain{}
+	}
+	b.pulseTrains[name] = train
+	b.activeBackgroundWorkers.Add(1)
+	utils.ManagedGo(func() { b.runPulseTrain(trainCtx, name, train) }, b.activeBackgroundWorkers.Done)
+	return nil
+}
+
+// runPulseTrain toggles the output of a train on a fixed schedule, so that the timing does not drift,
+// until the train completes or is stopped.
+func (b *revolutionPiBoard) runPulseTrain(ctx context.Context, name string, train *pulseTrain) {
+	start := time.Now()
+	period := train.on + train.off
+	for i := 0; train.count == 0 || i < train.count; i++ {
+		onAt := start.Add(time.Duration(i) * period)
+		if !utils.SelectContextOrWait(ctx, time.Until(onAt)) || !b.setPulseTrainOutput(name, train, true) {
+			return
+		}
+		if !utils.SelectContextOrWait(ctx, time.Until(onAt.Add(train.on))) || !b.setPulseTrainOutput(name, train, false) {
+			return
+		}
+		train.completed.Add(1)
+	}
+
+	b.pulsesMu.Lock()
+	defer b.pulsesMu.Unlock()
+	if b.pulseTrains[name] == train {
+		delete(b.pulseTrains, name)
+		b.endPulseTrain(name, train)
+	}
+}
+
+// setPulseTrainOutput sets the output of a train, returning false if the train was stopped or the write failed.
+func (b *revolutionPiBoard) setPulseTrainOutput(name string, train *pulseTrain, high bool) bool {
+	b.pulsesMu.Lock()
+	defer b.pulsesMu.Unlock()
+	// the train may have been replaced or stopped while waiting for the lock
+	if b.pulseTrains[name] != train {
+		return false
+	}
+	err := b.controlChip.setBitValue(train.address, train.bit, high, writeOrigin{api: startPulseTrainKey, name: name})
+	if err != nil {
+		b.logger.Errorf("stopping the pulse train of %s, failed to set it to %v: %v", name, high, err)
+		delete(b.pulseTrains, name)
+		b.endPulseTrain(name, train)
+		return false
+	}
+	return true
+}
+
+// endPulseTrain stops a train and reverts its output. It must be called with pulsesMu held.
+func (b *revolutionPiBoard) endPulseTrain(name string, train *pulseTrain) {
+	train.cancel()
+	err := b.controlChip.setBitValue(train.address, train.bit, train.revert, writeOrigin{api: stopPulseTrainKey, name: name})
+	if err != nil {
+		b.logger.Errorf("failed to revert %s to %v at the end of its pulse train: %v", name, train.revert, err)
+	}
+}
+
+// stopPulseTrains stops the trains of the given outputs, or every train if names is empty, reverting their outputs.
+// It returns the names of the stopped trains.
+func (b *revolutionPiBoard) stopPulseTrains(names []string) ([]string, error) {
+	b.pulsesMu.Lock()
+	defer b.pulsesMu.Unlock()
+	if len(names) == 0 {
+		for name := range b.pulseTrains {
+			names = append(names, name)
+		}
+	}
+	for _, name := range names {
+		if _, ok := b.pulseTrains[name]; !ok {
+			return nil, fmt.Errorf("%s has no pulse train", name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		b.endPulseTrain(name, b.pulseTrains[name])
+		delete(b.pulseTrains, name)
+	}
+	return names, nil
+}
+
+// pulseTrainStatus lists the running pulse trains, sorted by output name.
+func (b *revolutionPiBoard) pulseTrainStatus() []interface{} {
+	b.pulsesMu.Lock()
+	defer b.pulsesMu.Unlock()
+	names := make([]string, 0, len(b.pulseTrains))
+	for name := range b.pulseTrains {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	trains := make([]interface{}, 0, len(names))
+	for _, name := range names {
+		train := b.pulseTrains[name]
+		trains = append(trains, map[string]interface{}{
+			"name":      name,
+			"on_ms":     train.on.Milliseconds(),
+			"off_ms":    train.off.Milliseconds(),
+			"count":     train.count,
+			"completed": train.completed.Load(),
+		})
+	}
+	return trains
+}
@@ -0,0 +1,173 @@
+//go:build linux
+
+// Package revolutionpi implements the Revolution Pi.
+package revolutionpi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"go.viam.com/utils"
+)
+
+// pulseDurationKey can be set in the extra of Set to pulse the output for this many milliseconds.
+const pulseDurationKey = "pulse_ms"
+
+// pulse is an output set for a duration, after which it reverts to the value it had before the pulse.
+type pulse struct {
+	high    bool
+	revert  bool
+	address uint16
+	bit     uint8
+	until   time.Time
+	cancel  func()
+}
+
+// boardGPIOPin wraps a GPIO pin of the board, so that Set can pulse the output and Get can read the debounced input.
+type boardGPIOPin struct {
+	*gpioPin
+	board *revolutionPiBoard
+}
+
+// Set sets the state of the pin. If pulse_ms is set in extra, the pin reverts to its previous state after that many
+// milliseconds. Otherwise any pulse or pulse train of the pin is cancelled, and the pin keeps the new state.
+func (pin *boardGPIOPin) Set(ctx context.Context, high bool, extra map[string]interface{}) error {
+	if durationMs, exists := extra[pulseDurationKey]; exists {
+		ms, ok := durationMs.(float64)
+		if !ok || ms <= 0 {
+			return fmt.Errorf("expected positive %s got %v", pulseDurationKey, durationMs)
+		}
+		return pin.board.pulse(pin.Name, high, time.Duration(ms*float64(time.Millisecond)), "Set")
+	}
+	return pin.board.replacePulses([]string{pin.Name}, func() error { return pin.gpioPin.Set(ctx, high, extra) })
+}
+
+// pulse sets a digital output to high for duration, after which a background worker reverts it to the value it had
+// before the pulse. A pulse of an output that is already pulsing replaces the earlier pulse, and the output reverts
+// to the value it had before the earlier pulse once the new one ends.
+func (b *revolutionPiBoard) pulse(name string, high bool, duration time.Duration, api string) error {
+	if duration <= 0 {
+		return errors.New("pulse duration must be positive")
+	}
+	pin, err := b.controlChip.GetGPIOPin(name)
+	if err != nil {
+		return err
+	}
+	address, bit, err := pin.getOutputBit()
+	if err != nil {
+		return err
+	}
+
+	b.pulsesMu.Lock()
+	defer b.pulsesMu.Unlock()
+	if b.cancelCtx.Err() != nil {
+		return ErrBoardClosed
+	}
+	previous, pulsing := b.pulses[name]
+	train, training := b.pulseTrains[name]
+	var revert bool
+	if pulsing {
+		revert = previous.revert
+	} else if training {
+		revert = train.revert
+	} else if revert, err = b.controlChip.getBitValue(int64(address), bit, true); err != nil {
+		return err
+	}
+	if err := b.controlChip.setBitValue(address, bit, high, writeOrigin{api: api, name: name}); err != nil {
+		return err
+	}
+	if pulsing {
+		previous.cancel()
+	}
+	if training {
+		train.cancel()
+		delete(b.pulseTrains, name)
+	}
+
+	pulseCtx, cancel := context.WithCancel(b.cancelCtx)
+	p := &pulse{high: high, revert: revert, address: address, bit: bit, until: time.Now().Add(duration), cancel: cancel}
+	if b.pulses == nil {
+		b.pulses = map[string]*pulse{}
+	}
+	b.pulses[name] = p
+	b.activeBackgroundWorkers.Add(1)
+	utils.ManagedGo(func() {
+		if !utils.SelectContextOrWait(pulseCtx, duration) {
+			return
+		}
+		b.pulsesMu.Lock()
+		defer b.pulsesMu.Unlock()
+		// the pulse may have been replaced or cancelled while waiting for the lock
+		if b.pulses[name] != p {
+			return
+		}
+		delete(b.pulses, name)
+		b.endPulse(name, p)
+	}, b.activeBackgroundWorkers.Done)
+	return nil
+}
+
+// endPulse reverts an output at the end of its pulse. It must be called with pulsesMu held.
+func (b *revolutionPiBoard) endPulse(name string, p *pulse) {
+	p.cancel()
+	if err := b.controlChip.setBitValue(p.address, p.bit, p.revert, writeOrigin{api: pulseKey, name: name}); err != nil {
+		b.logger.Errorf("failed to revert %s to %v at the end of its pulse: %v", name, p.revert, err)
+	}
+}
+
+// replacePulses runs a write that replaces the pulses and pulse trains of the given outputs, and cancels them without
+// reverting once the write succeeds. An output whose write fails keeps its pulse, and still reverts when it ends.
+// pulsesMu is held during the write, so a pulse cannot end and revert its output between the write and the cancel.
+func (b *revolutionPiBoard) replacePulses(names []string, write func() error) error {
+	b.pulsesMu.Lock()
+	defer b.pulsesMu.Unlock()
+	if err := write(); err != nil {
+		return err
+	}
+	for _, name := range names {
+		if p, ok := b.pulses[name]; ok {
+			p.cancel()
+			delete(b.pulses, name)
+		}
+		if train, ok := b.pulseTrains[name]; ok {
+			train.cancel()
+			delete(b.pulseTrains, name)
+		}
+	}
+	return nil
+}
+
+// endPulses reverts every output that is still pulsing, when the board is closed.
+func (b *revolutionPiBoard) endPulses() {
+	b.pulsesMu.Lock()
+	defer b.pulsesMu.Unlock()
+	for name, p := range b.pulses {
+		b.endPulse(name, p)
+	}
+	b.pulses = nil
+}
+
+// pulseStatus lists the outputs that are pulsing, sorted by name.
+func (b *revolutionPiBoard) pulseStatus() []interface{} {
+	b.pulsesMu.Lock()
+	defer b.pulsesMu.Unlock()
+	names := make([]string, 0, len(b.pulses))
+	for name := range b.pulses {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	pulses := make([]interface{}, 0, len(names))
+	for _, name := range names {
+		p := b.pulses[name]
+		pulses = append(pulses, map[string]interface{}{
+			"name":      name,
+			"value":     p.high,
+			"revert_to": p.revert,
+			"until":     p.until.Format(time.RFC3339Nano),
+		})
+	}
+	return pulses
+}
@@ -5,27 +5,45 @@ package revolutionpi
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"io/fs"
 	"os"
 	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	pb "go.viam.com/api/component/board/v1"
+	"go.uber.org/multierr"
 	"go.viam.com/rdk/components/board"
 	"go.viam.com/rdk/grpc"
 	"go.viam.com/rdk/logging"
 	"go.viam.com/rdk/resource"
+	"go.viam.com/utils"
 )
 
 const (
-	readParameterKey = "readParameter"
+	readParameterKey   = "readParameter"
+	writeParameterKey  = "writeParameter"
+	getTotalizersKey   = "getTotalizers"
+	presetTotalizerKey = "presetTotalizer"
+	resetTotalizerKey  = "resetTotalizer"
+	getDeviceStatusKey = "getDeviceStatus"
+	getStatusKey       = "getStatus"
+	sampleAgeKey       = "sample_age_ms"
+	setOutputsKey      = "setOutputs"
+	forceKey           = "force"
+	listForcesKey      = "listForces"
+	clearForcesKey     = "clearForces"
+	getAuditLogKey     = "getAuditLog"
+	pulseKey           = "pulse"
+	startPulseTrainKey = "startPulseTrain"
+	stopPulseTrainKey  = "stopPulseTrain"
+	getEdgesKey        = "getEdges"
 )
 
 type revolutionPiBoard struct {
 	resource.Named
-	resource.TriviallyReconfigurable
+	resource.AlwaysRebuild
 
 	mu            sync.RWMutex
 	logger        logging.Logger
@@ -33,9 +51,26 @@ type revolutionPiBoard struct {
 	GPIONames     []string
 
 	controlChip             *gpioChip
+	totalizers              *totalizerManager
+	debouncer               *debounceManager
+	deviceMonitor           *deviceMonitor
 	cancelCtx               context.Context
 	cancelFunc              func()
 	activeBackgroundWorkers sync.WaitGroup
+
+	// outputs written before IO is stopped by SetPowerMode
+	safeOutputs map[string]interface{}
+	powerMu     sync.Mutex
+	power       powerState
+	// the longest time a force lasts
+	forceTimeout time.Duration
+	// outputs that are pulsing or running a pulse train, keyed by name
+	pulsesMu    sync.Mutex
+	pulses      map[string]*pulse
+	pulseTrains map[string]*pulseTrain
+	// the thread waiting for piControl events, and closed once the wait stopped
+	resetWaiterThread atomic.Int32
+	resetWaiterDone   chan struct{}
 }
 
 func init() {
@@ -53,15 +88,36 @@ func newBoard(
 ) (board.Board, error) {
 	logger.Info("Starting RevolutionPi Driver v0.0.9")
 
-	devPath := filepath.Join("/dev", "piControl0")
-	devPath = filepath.Clean(devPath)
-	fd, err := os.OpenFile(devPath, os.O_RDWR, fs.FileMode(os.O_RDWR))
+	newConf, err := resource.NativeConfig[*Config](conf)
+	if err != nil {
+		return nil, err
+	}
+
+	gpioChip, devices, err := openGpioChip(newConf.DevicePath, logger)
 	if err != nil {
-		err = fmt.Errorf("open chip %v failed: %w", devPath, err)
 		return nil, err
 	}
+	if err := checkRequiredModules(devices, newConf.RequiredModules, logger); err != nil {
+		return nil, multierr.Combine(err, gpioChip.Close())
+	}
+	for name, varType := range newConf.VariableTypes {
+		gpioChip.variableTypes[name] = varType
+	}
+	if newConf.ReadOnly || len(newConf.WritePolicies) > 0 || newConf.DefaultWritePolicy == writePolicyDeny {
+		gpioChip.policy = newWritePolicy(newConf.ReadOnly, newConf.DefaultWritePolicy == writePolicyDeny, newConf.WritePolicies)
+	}
+	if len(newConf.Interlocks) > 0 {
+		gpioChip.interlocks = newInterlocks(newConf.Interlocks)
+	}
+	if newConf.ScanIntervalMs > 0 {
+		gpioChip.scan = newScanLoop(gpioChip.readImage, time.Duration(newConf.ScanIntervalMs)*time.Millisecond, logger)
+		// serve reads from a scan as soon as the board is created
+		if err := gpioChip.scan.scan(); err != nil {
+			return nil, multierr.Combine(fmt.Errorf("failed to scan the process image: %w", err), gpioChip.Close())
+		}
+	}
+
 	cancelCtx, cancelFunc := context.WithCancel(context.Background())
-	gpioChip := gpioChip{dev: devPath, logger: logger, fileHandle: fd}
 	b := revolutionPiBoard{
 		Named:         conf.ResourceName().AsNamed(),
 		logger:        logger,
@@ -69,18 +125,148 @@ func newBoard(
 		cancelFunc:    cancelFunc,
 		AnalogReaders: []string{},
 		GPIONames:     []string{},
-		controlChip:   &gpioChip,
+		controlChip:   gpioChip,
 		mu:            sync.RWMutex{},
+		safeOutputs:   newConf.SafeOutputs,
+		forceTimeout:  time.Duration(newConf.ForceTimeoutSec * float64(time.Second)),
+	}
+	if b.forceTimeout <= 0 {
+		b.forceTimeout = defaultForceTimeout
 	}
 
-	err = b.controlChip.showDeviceList()
+	auditLogFile := newConf.AuditLogFile
+	if auditLogFile == "" {
+		if dataDir := os.Getenv("VIAM_MODULE_DATA"); dataDir != "" {
+			auditLogFile = filepath.Join(dataDir, conf.Name+"-audit.log")
+		} else {
+			logger.Warn("the module data directory is unknown, writes are only audited in memory unless audit_log_file is set")
+		}
+	}
+	gpioChip.audit, err = newAuditLog(auditLogFile, newConf.AuditLogMaxSizeMB, logger)
 	if err != nil {
-		return nil, err
+		return nil, multierr.Combine(err, gpioChip.Close())
+	}
+
+	if len(newConf.Totalizers) > 0 {
+		stateFile := newConf.TotalizerStateFile
+		if stateFile == "" {
+			dataDir := os.Getenv("VIAM_MODULE_DATA")
+			if dataDir == "" {
+				return nil, multierr.Combine(errors.New("totalizer_state_file is required when the module data directory is unknown"),
+					gpioChip.Close(), gpioChip.audit.Close())
+			}
+			stateFile = filepath.Join(dataDir, conf.Name+"-totalizers.json")
+		}
+		b.totalizers, err = newTotalizerManager(gpioChip, newConf.Totalizers, stateFile, logger)
+		if err != nil {
+			return nil, multierr.Combine(err, gpioChip.Close(), gpioChip.audit.Close())
+		}
 	}
 
+	if len(newConf.DebounceMs) > 0 {
+		b.debouncer, err = newDebounceManager(gpioChip, newConf.DebounceMs, logger)
+		if err != nil {
+			return nil, multierr.Combine(err, gpioChip.Close(), gpioChip.audit.Close())
+		}
+	}
+
+	monitorInterval := time.Duration(newConf.DeviceMonitorIntervalSec * float64(time.Second))
+	if monitorInterval <= 0 {
+		monitorInterval = defaultDeviceMonitorInterval
+	}
+	b.deviceMonitor = newDeviceMonitor(gpioChip, monitorInterval, logger)
+	b.deviceMonitor.poll()
+
+	b.startBackgroundWorkers()
+	if err := b.startConfigWatcher(); err != nil {
+		logger.Warnf("unable to watch the PiCtory configuration for changes: %v", err)
+	}
 	return &b, nil
 }
 
+// checkRequiredModules validates that the required modules are active. When no modules are listed as required,
+// every module in the device list is required. Missing optional modules are logged and leave the board degraded.
+func checkRequiredModules(devices []SDeviceInfo, required []RequiredModule, logger logging.Logger) error {
+	var deviceErrs error
+	if len(required) == 0 {
+		for i, dev := range devices {
+			deviceErrs = multierr.Combine(deviceErrs, dev.configurationError(i))
+		}
+		return deviceErrs
+	}
+
+	for _, module := range required {
+		found := false
+		for _, dev := range devices {
+			if dev.i8uActive != 0 && module.matches(dev) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			deviceErrs = multierr.Combine(deviceErrs, fmt.Errorf("required %s is not active", module))
+		}
+	}
+	if deviceErrs != nil {
+		return deviceErrs
+	}
+	for i, dev := range devices {
+		if err := dev.configurationError(i); err != nil {
+			logger.Warnf("starting in a degraded state, pins on this module are unavailable: %v", err)
+		}
+	}
+	return nil
+}
+
+// status reports whether the board is degraded because modules in the device list are not active.
+func (b *revolutionPiBoard) status() map[string]interface{} {
+	missing := []interface{}{}
+	for i, dev := range b.controlChip.getDevices() {
+		if err := dev.configurationError(i); err != nil {
+			missing = append(missing, map[string]interface{}{
+				"position": int(dev.i8uAddress),
+				"module":   getModuleName(dev.i16uModuleType &^ piControlNotConnected),
+				"error":    err.Error(),
+			})
+		}
+	}
+	status := map[string]interface{}{
+		"degraded":         len(missing) > 0,
+		"inactive_modules": missing,
+	}
+	if b.controlChip.scan != nil {
+		status["scan"] = b.controlChip.scan.status()
+	}
+	status["power"] = b.powerStatus()
+	status["read_only"] = b.controlChip.policy != nil && b.controlChip.policy.readOnly
+	status["pulses"] = b.pulseStatus()
+	status["pulse_trains"] = b.pulseTrainStatus()
+	forces := b.controlChip.listForces()
+	status["forced"] = len(forces) > 0
+	status["forces"] = forces
+	return status
+}
+
+// startBackgroundWorkers starts the workers that run until the board is closed.
+func (b *revolutionPiBoard) startBackgroundWorkers() {
+	b.activeBackgroundWorkers.Add(1)
+	utils.ManagedGo(func() { b.deviceMonitor.run(b.cancelCtx) }, b.activeBackgroundWorkers.Done)
+	b.activeBackgroundWorkers.Add(1)
+	utils.ManagedGo(func() { b.controlChip.runForces(b.cancelCtx) }, b.activeBackgroundWorkers.Done)
+	if b.controlChip.scan != nil {
+		b.activeBackgroundWorkers.Add(1)
+		utils.ManagedGo(func() { b.controlChip.scan.run(b.cancelCtx) }, b.activeBackgroundWorkers.Done)
+	}
+	if b.totalizers != nil {
+		b.activeBackgroundWorkers.Add(1)
+		utils.ManagedGo(func() { b.totalizers.run(b.cancelCtx) }, b.activeBackgroundWorkers.Done)
+	}
+	if b.debouncer != nil {
+		b.activeBackgroundWorkers.Add(1)
+		utils.ManagedGo(func() { b.debouncer.run(b.cancelCtx) }, b.activeBackgroundWorkers.Done)
+	}
+}
+
 // StreamTicks starts a stream of digital interrupt ticks. The rev pi does not support this feature.
 func (b *revolutionPiBoard) StreamTicks(ctx context.Context, interrupts []board.DigitalInterrupt,
 	ch chan board.Tick, extra map[string]interface{},
@@ -122,23 +308,52 @@ func (b *revolutionPiBoard) GPIOPinNames() []string {
 }
 
 func (b *revolutionPiBoard) GPIOPinByName(pinName string) (board.GPIOPin, error) {
-	return b.controlChip.GetGPIOPin(pinName)
-}
-
-func (b *revolutionPiBoard) SetPowerMode(ctx context.Context, mode pb.PowerMode, duration *time.Duration) error {
-	return grpc.UnimplementedError
+	pin, err := b.controlChip.GetGPIOPin(pinName)
+	if err != nil {
+		return nil, err
+	}
+	return &boardGPIOPin{gpioPin: pin, board: b}, nil
 }
 
 func (b *revolutionPiBoard) Close(ctx context.Context) error {
 	b.mu.Lock()
 	b.logger.Info("Closing RevPi board.")
 	defer b.mu.Unlock()
+	// cancel while holding powerMu and pulsesMu, so SetPowerMode cannot schedule a resume and pulses cannot start
+	// after the workers are waited on
+	b.powerMu.Lock()
+	b.pulsesMu.Lock()
 	b.cancelFunc()
-	err := b.controlChip.Close()
+	b.pulsesMu.Unlock()
+	b.powerMu.Unlock()
+	b.stopResetWaiter()
+	// wait for the background workers before closing the chip they read from
+	b.activeBackgroundWorkers.Wait()
+
+	// outputs left pulsing would otherwise stay on
+	b.endPulses()
+	if _, err := b.stopPulseTrains(nil); err != nil {
+		b.logger.Errorf("failed to stop pulse trains: %v", err)
+	}
+
+	// IO stopped by the board would otherwise stay stopped with nothing left to resume it
+	b.powerMu.Lock()
+	if b.power.ioStopped {
+		if err := b.resumeIO(); err != nil {
+			b.logger.Errorf("failed to resume process image IO: %v", err)
+		}
+	}
+	b.powerMu.Unlock()
+
+	// forced outputs return to the last values written to them
+	if _, err := b.controlChip.clearForces(nil, "the board is closing"); err != nil {
+		b.logger.Errorf("failed to restore forced outputs: %v", err)
+	}
+
+	err := multierr.Combine(b.controlChip.Close(), b.controlChip.audit.Close())
 	if err != nil {
 		return err
 	}
-	b.activeBackgroundWorkers.Wait()
 	b.logger.Info("Board closed.")
 	return nil
 }
@@ -147,44 +362,322 @@ func (b *revolutionPiBoard) DoCommand(ctx context.Context,
 	req map[string]interface{},
 ) (map[string]interface{}, error) {
 	resp := make(map[string]interface{})
+	found := false
 
-	pinMessage, exists := req[readParameterKey]
-	if exists {
+	if pinMessage, exists := req[readParameterKey]; exists {
+		found = true
 		pinName, ok := pinMessage.(string)
 		if !ok {
 			return nil, fmt.Errorf("error performing %s: expected string got %v", readParameterKey, pinMessage)
 		}
-		pin := SPIVariable{strVarName: char32(pinName)}
-		err := b.controlChip.mapNameToAddress(&pin)
+		direct, _ := req[directKey].(bool)
+		value, sampled, err := b.readParameter(pinName, direct)
 		if err != nil {
 			return nil, err
 		}
-		b.controlChip.logger.Debugf("reading pin: %#v", pin)
-		switch pin.i16uLength {
-		case 1:
-			// the length of the variable is 1, so we want to read from a specific bit at the address
-			value, err := b.controlChip.getBitValue(int64(pin.i16uAddress), pin.i8uBit)
-			if err != nil {
-				return nil, err
+		resp[pinName] = value
+		if !sampled.IsZero() {
+			resp[sampleAgeKey] = time.Since(sampled).Milliseconds()
+		}
+	}
+	if writeMessage, exists := req[writeParameterKey]; exists {
+		found = true
+		write, ok := writeMessage.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("error performing %s: expected object got %v", writeParameterKey, writeMessage)
+		}
+		pinName, ok := write["name"].(string)
+		if !ok {
+			return nil, fmt.Errorf("error performing %s: expected string name got %v", writeParameterKey, write["name"])
+		}
+		err := b.replacePulses([]string{pinName}, func() error {
+			return b.writeParameter(pinName, write["value"], writeParameterKey)
+		})
+		if err != nil {
+			return nil, err
+		}
+		resp[writeParameterKey] = pinName
+	}
+	if outputsMessage, exists := req[setOutputsKey]; exists {
+		found = true
+		outputsMap, ok := outputsMessage.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("error performing %s: expected object got %v", setOutputsKey, outputsMessage)
+		}
+		outputs := make(map[string]bool, len(outputsMap))
+		names := make([]string, 0, len(outputsMap))
+		for name, value := range outputsMap {
+			high, ok := value.(bool)
+			if !ok {
+				return nil, fmt.Errorf("error performing %s: expected bool for %s got %v", setOutputsKey, name, value)
 			}
-			resp[pinName] = value
-		default:
-			// the length of the variable is more than 1, so we want to read a set of bytes from the address
-			value := make([]byte, pin.i16uLength/8)
-			n, err := b.controlChip.fileHandle.ReadAt(value, int64(pin.i16uAddress))
-			if err != nil {
-				return nil, err
+			outputs[name] = high
+			names = append(names, name)
+		}
+		if err := b.replacePulses(names, func() error { return b.controlChip.SetOutputs(outputs) }); err != nil {
+			return nil, err
+		}
+		resp[setOutputsKey] = outputsMap
+	}
+	if forceMessage, exists := req[forceKey]; exists {
+		found = true
+		forceReq, ok := forceMessage.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("error performing %s: expected object got %v", forceKey, forceMessage)
+		}
+		pinName, ok := forceReq["name"].(string)
+		if !ok {
+			return nil, fmt.Errorf("error performing %s: expected string name got %v", forceKey, forceReq["name"])
+		}
+		timeout := b.forceTimeout
+		if timeoutMessage, exists := forceReq["timeout_sec"]; exists {
+			timeoutSec, ok := timeoutMessage.(float64)
+			if !ok || timeoutSec <= 0 {
+				return nil, fmt.Errorf("error performing %s: expected positive timeout_sec got %v", forceKey, timeoutMessage)
+			}
+			timeout = min(timeout, time.Duration(timeoutSec*float64(time.Second)))
+		}
+		if err := b.controlChip.forceVariable(pinName, forceReq["value"], timeout); err != nil {
+			return nil, err
+		}
+		resp[forceKey] = pinName
+	}
+	if _, exists := req[listForcesKey]; exists {
+		found = true
+		resp[listForcesKey] = b.controlChip.listForces()
+	}
+	if clearMessage, exists := req[clearForcesKey]; exists {
+		found = true
+		// true clears every force, a list of names clears only those
+		var names []string
+		if list, ok := clearMessage.([]interface{}); ok && len(list) > 0 {
+			for _, name := range list {
+				pinName, ok := name.(string)
+				if !ok {
+					return nil, fmt.Errorf("error performing %s: expected string names got %v", clearForcesKey, name)
+				}
+				names = append(names, pinName)
+			}
+		} else if clearAll, ok := clearMessage.(bool); !ok || !clearAll {
+			return nil, fmt.Errorf("error performing %s: expected true or a list of names got %v", clearForcesKey, clearMessage)
+		}
+		cleared, err := b.controlChip.clearForces(names, "cleared by "+clearForcesKey)
+		if err != nil {
+			return nil, err
+		}
+		clearedNames := make([]interface{}, 0, len(cleared))
+		for _, name := range cleared {
+			clearedNames = append(clearedNames, name)
+		}
+		resp[clearForcesKey] = clearedNames
+	}
+	if pulseMessage, exists := req[pulseKey]; exists {
+		found = true
+		pulseReq, ok := pulseMessage.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("error performing %s: expected object got %v", pulseKey, pulseMessage)
+		}
+		pinName, ok := pulseReq["name"].(string)
+		if !ok {
+			return nil, fmt.Errorf("error performing %s: expected string name got %v", pulseKey, pulseReq["name"])
+		}
+		durationMs, ok := pulseReq["duration_ms"].(float64)
+		if !ok || durationMs <= 0 {
+			return nil, fmt.Errorf("error performing %s: expected positive duration_ms got %v", pulseKey, pulseReq["duration_ms"])
+		}
+		high := true
+		if value, exists := pulseReq["value"]; exists {
+			if high, ok = value.(bool); !ok {
+				return nil, fmt.Errorf("error performing %s: expected bool value got %v", pulseKey, value)
 			}
-			b.controlChip.logger.Debugf("Read %#d bytes", n)
-			resp[pinName], err = readFromBuffer(value, n)
-			if err != nil {
-				return nil, err
+		}
+		if err := b.pulse(pinName, high, time.Duration(durationMs*float64(time.Millisecond)), pulseKey); err != nil {
+			return nil, err
+		}
+		resp[pulseKey] = pinName
+	}
+	if trainMessage, exists := req[startPulseTrainKey]; exists {
+		found = true
+		trainReq, ok := trainMessage.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("error performing %s: expected object got %v", startPulseTrainKey, trainMessage)
+		}
+		pinName, ok := trainReq["name"].(string)
+		if !ok {
+			return nil, fmt.Errorf("error performing %s: expected string name got %v", startPulseTrainKey, trainReq["name"])
+		}
+		onMs, ok := trainReq["on_ms"].(float64)
+		if !ok || onMs <= 0 {
+			return nil, fmt.Errorf("error performing %s: expected positive on_ms got %v", startPulseTrainKey, trainReq["on_ms"])
+		}
+		offMs, ok := trainReq["off_ms"].(float64)
+		if !ok || offMs <= 0 {
+			return nil, fmt.Errorf("error performing %s: expected positive off_ms got %v", startPulseTrainKey, trainReq["off_ms"])
+		}
+		// without a count the train runs until it is stopped
+		count := 0.0
+		if countMessage, exists := trainReq["count"]; exists {
+			if count, ok = countMessage.(float64); !ok || count < 1 || count != float64(int(count)) {
+				return nil, fmt.Errorf("error performing %s: expected positive integer count got %v", startPulseTrainKey, countMessage)
+			}
+		}
+		on := time.Duration(onMs * float64(time.Millisecond))
+		off := time.Duration(offMs * float64(time.Millisecond))
+		if err := b.startPulseTrain(pinName, on, off, int(count)); err != nil {
+			return nil, err
+		}
+		resp[startPulseTrainKey] = pinName
+	}
+	if stopMessage, exists := req[stopPulseTrainKey]; exists {
+		found = true
+		// true stops every train, a name or a list of names stops only those
+		var names []string
+		switch msg := stopMessage.(type) {
+		case string:
+			names = []string{msg}
+		case []interface{}:
+			for _, name := range msg {
+				pinName, ok := name.(string)
+				if !ok {
+					return nil, fmt.Errorf("error performing %s: expected string names got %v", stopPulseTrainKey, name)
+				}
+				names = append(names, pinName)
+			}
+		}
+		if stopAll, ok := stopMessage.(bool); len(names) == 0 && (!ok || !stopAll) {
+			return nil, fmt.Errorf("error performing %s: expected true, a name or a list of names got %v", stopPulseTrainKey, stopMessage)
+		}
+		stopped, err := b.stopPulseTrains(names)
+		if err != nil {
+			return nil, err
+		}
+		stoppedNames := make([]interface{}, 0, len(stopped))
+		for _, name := range stopped {
+			stoppedNames = append(stoppedNames, name)
+		}
+		resp[stopPulseTrainKey] = stoppedNames
+	}
+	if auditMessage, exists := req[getAuditLogKey]; exists {
+		found = true
+		// true returns every entry kept in memory, a number returns at most that many of the most recent
+		limit := 0
+		if num, ok := auditMessage.(float64); ok && num >= 1 {
+			limit = int(num)
+		} else if all, ok := auditMessage.(bool); !ok || !all {
+			return nil, fmt.Errorf("error performing %s: expected true or a positive number got %v", getAuditLogKey, auditMessage)
+		}
+		resp[getAuditLogKey] = b.controlChip.audit.recent(limit)
+	}
+	if edgesMessage, exists := req[getEdgesKey]; exists {
+		found = true
+		if b.debouncer == nil {
+			return nil, fmt.Errorf("error performing %s: no inputs are configured for debouncing", getEdgesKey)
+		}
+		// true returns every debounced input, a name or a list of names returns only those
+		var names []string
+		switch msg := edgesMessage.(type) {
+		case string:
+			names = []string{msg}
+		case []interface{}:
+			for _, name := range msg {
+				pinName, ok := name.(string)
+				if !ok {
+					return nil, fmt.Errorf("error performing %s: expected string names got %v", getEdgesKey, name)
+				}
+				names = append(names, pinName)
 			}
 		}
+		if all, ok := edgesMessage.(bool); len(names) == 0 && (!ok || !all) {
+			return nil, fmt.Errorf("error performing %s: expected true, a name or a list of names got %v", getEdgesKey, edgesMessage)
+		}
+		edges, err := b.debouncer.edges(names)
+		if err != nil {
+			return nil, err
+		}
+		resp[getEdgesKey] = edges
+	}
+	if _, exists := req[getDeviceStatusKey]; exists {
+		found = true
+		resp[getDeviceStatusKey] = b.deviceMonitor.status()
+	}
+	if _, exists := req[getStatusKey]; exists {
+		found = true
+		resp[getStatusKey] = b.status()
+	}
+	if _, exists := req[getTotalizersKey]; exists {
+		found = true
+		if b.totalizers == nil {
+			return nil, fmt.Errorf("error performing %s: no totalizers are configured", getTotalizersKey)
+		}
+		resp[getTotalizersKey] = b.totalizers.totals()
+	}
+	if presetMessage, exists := req[presetTotalizerKey]; exists {
+		found = true
+		if b.totalizers == nil {
+			return nil, fmt.Errorf("error performing %s: no totalizers are configured", presetTotalizerKey)
+		}
+		preset, ok := presetMessage.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("error performing %s: expected object got %v", presetTotalizerKey, presetMessage)
+		}
+		pinName, ok := preset["pin_name"].(string)
+		if !ok {
+			return nil, fmt.Errorf("error performing %s: expected string pin_name got %v", presetTotalizerKey, preset["pin_name"])
+		}
+		value, ok := preset["value"].(float64)
+		if !ok || value < 0 {
+			return nil, fmt.Errorf("error performing %s: expected non-negative value got %v", presetTotalizerKey, preset["value"])
+		}
+		if err := b.totalizers.preset(pinName, uint64(value)); err != nil {
+			return nil, err
+		}
+		resp[presetTotalizerKey] = pinName
 	}
-	if !exists {
+	if pinMessage, exists := req[resetTotalizerKey]; exists {
+		found = true
+		if b.totalizers == nil {
+			return nil, fmt.Errorf("error performing %s: no totalizers are configured", resetTotalizerKey)
+		}
+		pinName, ok := pinMessage.(string)
+		if !ok {
+			return nil, fmt.Errorf("error performing %s: expected string got %v", resetTotalizerKey, pinMessage)
+		}
+		if err := b.totalizers.preset(pinName, 0); err != nil {
+			return nil, err
+		}
+		resp[resetTotalizerKey] = pinName
+	}
+
+	if !found {
 		return nil, fmt.Errorf("no valid commands found, got %#v", req)
 	}
 
 	return resp, nil
 }
+
+// readParameter reads the value of any variable defined in PiCtory, returning the time of the scan it was read from
+// or the zero time if it was read from the process image.
+func (b *revolutionPiBoard) readParameter(pinName string, direct bool) (interface{}, time.Time, error) {
+	pin := SPIVariable{strVarName: char32(pinName)}
+	err := b.controlChip.mapNameToAddress(&pin)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	b.controlChip.logger.Debugf("reading pin: %#v", pin)
+	values, sampled, err := b.controlChip.readVariables([]SPIVariable{pin}, direct)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return values[0], sampled, nil
+}
+
+// writeParameter writes a value to any variable defined in PiCtory.
+func (b *revolutionPiBoard) writeParameter(pinName string, value interface{}, api string) error {
+	pin := SPIVariable{strVarName: char32(pinName)}
+	err := b.controlChip.mapNameToAddress(&pin)
+	if err != nil {
+		return err
+	}
+	b.controlChip.logger.Debugf("writing %v to pin: %#v", value, pin)
+	return b.controlChip.writeVariable(pin, value, api)
+}
@@ -0,0 +1,106 @@
+//go:build linux
+
+// Package revolutionpi implements the Revolution Pi.
+package revolutionpi
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.viam.com/rdk/logging"
+)
+
+const (
+	// processImageSize is the size of the piControl process image in bytes.
+	processImageSize = 4096
+	// scans older than this many intervals are stale, e.g. when scanning failed, and reads fall back to the process image.
+	staleScanIntervals = 10
+	// directKey can be set in extra to read from the process image instead of the latest scan.
+	directKey = "direct"
+)
+
+// scanLoop copies the whole process image every interval, so that reads can be served from memory rather than
+// each performing a read of the process image. The image is double buffered: a scan is read into the back buffer
+// and swapped with the front buffer, which readers copy from.
+type scanLoop struct {
+	readImage func(buf []byte, address int64) (int, error)
+	interval  time.Duration
+	logger    logging.Logger
+
+	mu      sync.RWMutex
+	front   []byte
+	back    []byte
+	sampled time.Time // when the front buffer was read
+}
+
+func newScanLoop(readImage func(buf []byte, address int64) (int, error), interval time.Duration, logger logging.Logger) *scanLoop {
+	return &scanLoop{
+		readImage: readImage, interval: interval, logger: logger,
+		front: make([]byte, processImageSize), back: make([]byte, processImageSize),
+	}
+}
+
+// run scans the process image until ctx is cancelled.
+func (s *scanLoop) run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.scan(); err != nil {
+				s.logger.Debugf("failed to scan the process image: %v", err)
+			}
+		}
+	}
+}
+
+// scan reads the process image into the back buffer and makes it the front buffer.
+// Only the scan loop writes to the back buffer, so it is read without holding the lock.
+func (s *scanLoop) scan() error {
+	n, err := s.readImage(s.back[:cap(s.back)], 0)
+	if err != nil && n == 0 {
+		return err
+	}
+	sampled := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.front, s.back = s.back[:n], s.front
+	s.sampled = sampled
+	return nil
+}
+
+// read copies len(buf) bytes at address from the latest scan, returning when the scan was read.
+// It returns false if there is no recent scan containing the bytes, in which case the process image should be read.
+func (s *scanLoop) read(buf []byte, address int64) (time.Time, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.sampled.IsZero() || time.Since(s.sampled) > staleScanIntervals*s.interval {
+		return time.Time{}, false
+	}
+	if address < 0 || address+int64(len(buf)) > int64(len(s.front)) {
+		return time.Time{}, false
+	}
+	copy(buf, s.front[address:])
+	return s.sampled, true
+}
+
+// status returns the interval and the age of the latest scan.
+func (s *scanLoop) status() map[string]interface{} {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	status := map[string]interface{}{"interval_ms": s.interval.Milliseconds()}
+	if !s.sampled.IsZero() {
+		status["sample_age_ms"] = time.Since(s.sampled).Milliseconds()
+	}
+	return status
+}
+
+// isDirect checks whether extra requests a read from the process image rather than the latest scan.
+func isDirect(extra map[string]interface{}) bool {
+	direct, _ := extra[directKey].(bool)
+	return direct
+}
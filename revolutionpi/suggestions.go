@@ -0,0 +1,116 @@
+//go:build linux
+
+// Package revolutionpi implements the Revolution Pi.
+package revolutionpi
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	// the most names suggested for an unknown variable.
+	maxSuggestions = 3
+	// the largest edit distance of a suggested name, so unrelated names are not suggested.
+	maxSuggestionDistance = 3
+)
+
+// variableNotFoundError returns an error for a variable that does not exist, suggesting the closest names
+// from the PiCtory configuration.
+func variableNotFoundError(name string, variables map[string]piCtoryVariable, cause error) error {
+	err := fmt.Errorf("%w: %s", ErrVariableNotFound, name)
+	if cause != nil {
+		err = fmt.Errorf("%w: %w", err, cause)
+	}
+	suggestions := suggestVariables(name, variables)
+	if len(suggestions) == 0 {
+		return err
+	}
+	described := make([]string, 0, len(suggestions))
+	for _, variable := range suggestions {
+		described = append(described, fmt.Sprintf("%s (%s)", variable.Name, describeVariable(variable)))
+	}
+	return fmt.Errorf("%w, did you mean %s?", err, strings.Join(described, " or "))
+}
+
+// suggestVariables returns the variables with names closest to name, closest first.
+func suggestVariables(name string, variables map[string]piCtoryVariable) []piCtoryVariable {
+	type candidate struct {
+		variable piCtoryVariable
+		distance int
+	}
+	limit := min(maxSuggestionDistance, max(len(name)/2, 1))
+	candidates := []candidate{}
+	for varName, variable := range variables {
+		distance := levenshtein(strings.ToLower(name), strings.ToLower(varName))
+		if distance <= limit {
+			candidates = append(candidates, candidate{variable: variable, distance: distance})
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].distance != candidates[j].distance {
+			return candidates[i].distance < candidates[j].distance
+		}
+		return candidates[i].variable.Name < candidates[j].variable.Name
+	})
+
+	suggestions := make([]piCtoryVariable, 0, maxSuggestions)
+	for i := 0; i < len(candidates) && i < maxSuggestions; i++ {
+		suggestions = append(suggestions, candidates[i].variable)
+	}
+	return suggestions
+}
+
+// describeVariable describes what kind of pin a variable is and which module it belongs to.
+func describeVariable(variable piCtoryVariable) string {
+	return fmt.Sprintf("%s on %s at position %d", variableKind(variable), getModuleName(variable.ProductType), variable.Position)
+}
+
+// variableKind returns the kind of pin a variable can be used as.
+func variableKind(variable piCtoryVariable) string {
+	dev := SDeviceInfo{i16uModuleType: variable.ProductType}
+	switch {
+	case dev.isDIO() && variable.Section == "inp" && variable.Length == 1:
+		return "digital input"
+	case dev.isDIO() && variable.Section == "out" && variable.Length == 1:
+		return "digital output"
+	case dev.isDIO() && variable.Section == "inp" && variable.Length == 32:
+		return "counter"
+	case dev.isDIO() && variable.Section == "out" && variable.Length == 16:
+		return "PWM output"
+	case isSignedAIOValue(variable) && variable.Section == "inp":
+		return "analog input"
+	case isSignedAIOValue(variable) && variable.Section == "out":
+		return "analog output"
+	}
+	switch variable.Section {
+	case "inp":
+		return fmt.Sprintf("%d bit input", variable.Length)
+	case "out":
+		return fmt.Sprintf("%d bit output", variable.Length)
+	default:
+		return fmt.Sprintf("%d bit memory variable", variable.Length)
+	}
+}
+
+// levenshtein returns the number of single character edits needed to turn a into b.
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
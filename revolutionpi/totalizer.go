@@ -0,0 +1,233 @@
+//go:build linux
+
+// Package revolutionpi implements the Revolution Pi board GPIO pins.
+package revolutionpi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/multierr"
+	"go.viam.com/rdk/logging"
+)
+
+const (
+	totalizerSampleInterval  = 100 * time.Millisecond
+	totalizerPersistInterval = 5 * time.Second
+)
+
+// bootIDPath holds a random id that changes every time the system boots.
+var bootIDPath = "/proc/sys/kernel/random/boot_id"
+
+// totalizer accumulates the pulses of one DIO counter, surviving hardware counter resets.
+type totalizer struct {
+	pin       *counterPin
+	lastCount uint32
+	total     uint64
+	resets    int
+}
+
+// totalizerState is the persisted state of a totalizer. DIO counters restart from 0 when the system boots or the
+// module is replaced, so the boot id and the serial number of the module mark which counter LastCount was read from.
+type totalizerState struct {
+	LastCount uint32 `json:"last_count"`
+	Total     uint64 `json:"total"`
+	BootID    string `json:"boot_id,omitempty"`
+	Serial    uint32 `json:"serial,omitempty"`
+}
+
+// totalizerManager keeps the totalizers for the configured counter pins and persists them to a state file.
+type totalizerManager struct {
+	logger     logging.Logger
+	stateFile  string
+	bootID     string
+	persistMu  sync.Mutex // serializes writes of the state file
+	mu         sync.Mutex
+	totalizers map[string]*totalizer
+	dirty      bool
+}
+
+// newTotalizerManager creates totalizers for the given counter pins, continuing from any state previously persisted
+// to stateFile. Pulses counted while the module was not running are included when the hardware counter was not reset.
+func newTotalizerManager(g *gpioChip, pinNames []string, stateFile string, logger logging.Logger) (*totalizerManager, error) {
+	m := &totalizerManager{logger: logger, stateFile: stateFile, bootID: readBootID(logger), totalizers: map[string]*totalizer{}}
+	saved, err := m.load()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range pinNames {
+		if _, ok := m.totalizers[name]; ok {
+			return nil, fmt.Errorf("totalizer for pin %s is configured more than once", name)
+		}
+		pin, err := g.GetDigitalInterrupt(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to set up totalizer for pin %s: %w", name, err)
+		}
+		count, err := pin.Value()
+		if err != nil {
+			return nil, err
+		}
+		t := &totalizer{pin: pin, lastCount: count}
+		if state, ok := saved[name]; ok {
+			delta, reset := counterDelta(state.LastCount, count)
+			// state saved before the marker was persisted can only detect resets by the count going down
+			if state.BootID != "" && (state.BootID != m.bootID || state.Serial != pin.serialNumber) {
+				delta, reset = count, true
+			}
+			if reset {
+				t.resets++
+				logger.Infof("counter %s was reset while the module was stopped", name)
+			}
+			t.total = state.Total + uint64(delta)
+		}
+		m.totalizers[name] = t
+	}
+	m.dirty = true
+	return m, nil
+}
+
+// run samples the counters until ctx is cancelled, periodically persisting the totals.
+func (m *totalizerManager) run(ctx context.Context) {
+	sampleTicker := time.NewTicker(totalizerSampleInterval)
+	defer sampleTicker.Stop()
+	persistTicker := time.NewTicker(totalizerPersistInterval)
+	defer persistTicker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			if err := m.persist(); err != nil {
+				m.logger.Errorf("failed to save totalizers: %v", err)
+			}
+			return
+		case <-sampleTicker.C:
+			m.sample()
+		case <-persistTicker.C:
+			if err := m.persist(); err != nil {
+				m.logger.Errorf("failed to save totalizers: %v", err)
+			}
+		}
+	}
+}
+
+func (m *totalizerManager) sample() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for name, t := range m.totalizers {
+		count, err := t.pin.Value()
+		if err != nil {
+			m.logger.Errorf("failed to read counter %s: %v", name, err)
+			continue
+		}
+		delta, reset := counterDelta(t.lastCount, count)
+		if reset {
+			t.resets++
+			m.logger.Infof("counter %s was reset, last count %d, current count %d", name, t.lastCount, count)
+		}
+		if delta != 0 || reset {
+			m.dirty = true
+		}
+		t.lastCount = count
+		t.total += uint64(delta)
+	}
+}
+
+// totals returns the current total of every totalizer.
+func (m *totalizerManager) totals() map[string]interface{} {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	resp := make(map[string]interface{}, len(m.totalizers))
+	for name, t := range m.totalizers {
+		resp[name] = map[string]interface{}{"total": t.total, "counter_resets": t.resets}
+	}
+	return resp
+}
+
+// preset sets the total of a totalizer, for example to match the reading of a mechanical meter.
+func (m *totalizerManager) preset(name string, value uint64) error {
+	m.mu.Lock()
+	t, ok := m.totalizers[name]
+	if !ok {
+		m.mu.Unlock()
+		return fmt.Errorf("no totalizer configured for pin %s", name)
+	}
+	t.total = value
+	m.dirty = true
+	m.mu.Unlock()
+	return m.persist()
+}
+
+// readBootID reads the id of the current boot, or returns an empty id if it is unavailable, in which case resets
+// while the module was stopped are only detected by the count going down.
+func readBootID(logger logging.Logger) string {
+	data, err := os.ReadFile(bootIDPath)
+	if err != nil {
+		logger.Warnf("unable to read the boot id, counter resets while stopped may be missed: %v", err)
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// load reads the persisted totalizer state. A missing state file means no totalizer has been saved yet.
+func (m *totalizerManager) load() (map[string]totalizerState, error) {
+	saved := map[string]totalizerState{}
+	data, err := os.ReadFile(m.stateFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return saved, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read totalizer state %s: %w", m.stateFile, err)
+	}
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, fmt.Errorf("failed to parse totalizer state %s: %w", m.stateFile, err)
+	}
+	return saved, nil
+}
+
+// persist atomically replaces the state file with the current totals, so a crash leaves either the old or the new state.
+func (m *totalizerManager) persist() error {
+	m.persistMu.Lock()
+	defer m.persistMu.Unlock()
+	m.mu.Lock()
+	if !m.dirty {
+		m.mu.Unlock()
+		return nil
+	}
+	state := make(map[string]totalizerState, len(m.totalizers))
+	for name, t := range m.totalizers {
+		state[name] = totalizerState{LastCount: t.lastCount, Total: t.total, BootID: m.bootID, Serial: t.pin.serialNumber}
+	}
+	m.dirty = false
+	m.mu.Unlock()
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(m.stateFile), filepath.Base(m.stateFile)+".tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	err = multierr.Combine(err, tmp.Close())
+	if err == nil {
+		err = os.Rename(tmp.Name(), m.stateFile)
+	}
+	if err != nil {
+		m.mu.Lock()
+		m.dirty = true
+		m.mu.Unlock()
+		return multierr.Combine(err, os.Remove(tmp.Name()))
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+//go:build linux
+
+// Package revolutionpi implements the Revolution Pi board GPIO pins.
+package revolutionpi
+
+import (
+	"context"
+	"fmt"
+
+	"go.uber.org/multierr"
+	"go.viam.com/rdk/components/sensor"
+	"go.viam.com/rdk/grpc"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/utils"
+)
+
+// SensorModel is the model triplet for the rev-pi sensor that publishes PiCtory variables.
+var SensorModel = resource.NewModel("viam", "kunbus", "revolutionpi-sensor")
+
+// SensorConfig is the config for the rev-pi sensor.
+type SensorConfig struct {
+	Variables  []SensorVariableConfig `json:"variables"`
+	DevicePath string                 `json:"device_path,omitempty"`
+}
+
+// SensorVariableConfig describes a PiCtory variable to publish as a reading.
+// Numeric values are reported as value * scale + offset.
+type SensorVariableConfig struct {
+	Name string `json:"name"`
+	// Type and Bits optionally declare how the variable is decoded, see VariableTypeConfig.
+	Type   string           `json:"type,omitempty"`
+	Bits   map[string]uint8 `json:"bits,omitempty"`
+	Scale  *float64         `json:"scale,omitempty"`
+	Offset float64          `json:"offset,omitempty"`
+	Units  string           `json:"units,omitempty"`
+}
+
+// revolutionPiSensor reads a set of PiCtory variables, such as RevPiStatus or gateway registers, as sensor readings.
+type revolutionPiSensor struct {
+	resource.Named
+	resource.AlwaysRebuild
+	chip      *gpioChip
+	variables []SensorVariableConfig
+	pins      []SPIVariable
+}
+
+func init() {
+	resource.RegisterComponent(
+		sensor.API,
+		SensorModel,
+		resource.Registration[sensor.Sensor, *SensorConfig]{Constructor: newSensor})
+}
+
+// Validate validates the SensorConfig.
+func (cfg *SensorConfig) Validate(path string) ([]string, error) {
+	if len(cfg.Variables) == 0 {
+		return nil, utils.NewConfigValidationFieldRequiredError(path, "variables")
+	}
+	if err := validateDevicePath(cfg.DevicePath); err != nil {
+		return nil, utils.NewConfigValidationError(path, err)
+	}
+	seen := map[string]bool{}
+	for i, variable := range cfg.Variables {
+		if variable.Name == "" {
+			return nil, utils.NewConfigValidationFieldRequiredError(fmt.Sprintf("%s.variables.%d", path, i), "name")
+		}
+		if seen[variable.Name] {
+			return nil, utils.NewConfigValidationError(path, fmt.Errorf("variable %s is listed more than once", variable.Name))
+		}
+		seen[variable.Name] = true
+		if variable.Type != "" {
+			if err := variable.typeConfig().Validate(); err != nil {
+				return nil, utils.NewConfigValidationError(path, fmt.Errorf("variable %s: %w", variable.Name, err))
+			}
+		}
+	}
+	return []string{}, nil
+}
+
+func newSensor(
+	ctx context.Context,
+	_ resource.Dependencies,
+	conf resource.Config,
+	logger logging.Logger,
+) (sensor.Sensor, error) {
+	svcConfig, err := resource.NativeConfig[*SensorConfig](conf)
+	if err != nil {
+		return nil, err
+	}
+	chip, err := newGpioChip(svcConfig.DevicePath, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	pins := make([]SPIVariable, 0, len(svcConfig.Variables))
+	for _, variable := range svcConfig.Variables {
+		if variable.Type != "" {
+			chip.variableTypes[variable.Name] = variable.typeConfig()
+		}
+		pin := SPIVariable{strVarName: char32(variable.Name)}
+		err = chip.mapNameToAddress(&pin)
+		if err != nil {
+			return nil, multierr.Combine(fmt.Errorf("variable %s: %w", variable.Name, err), chip.Close())
+		}
+		pins = append(pins, pin)
+	}
+
+	return &revolutionPiSensor{
+		Named:     conf.ResourceName().AsNamed(),
+		chip:      chip,
+		variables: svcConfig.Variables,
+		pins:      pins,
+	}, nil
+}
+
+// Readings returns every configured variable, read from the same piControl cycle.
+func (s *revolutionPiSensor) Readings(ctx context.Context, extra map[string]interface{}) (map[string]interface{}, error) {
+	values, _, err := s.chip.readVariables(s.pins, false)
+	if err != nil {
+		return nil, err
+	}
+
+	readings := make(map[string]interface{}, len(values))
+	for i, variable := range s.variables {
+		value := values[i]
+		if variable.Scale != nil || variable.Offset != 0 {
+			value, err = scaleValue(value, variable)
+			if err != nil {
+				return nil, err
+			}
+		}
+		readings[variable.Name] = readingValue(value)
+		if variable.Units != "" {
+			readings[variable.Name+"_units"] = variable.Units
+		}
+	}
+	return readings, nil
+}
+
+// readingValue widens a decoded value to int64 or float64, the numeric types readings are carried as.
+func readingValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case int32:
+		return int64(v)
+	case uint32:
+		return int64(v)
+	case float32:
+		return float64(v)
+	case map[string]interface{}:
+		field := make(map[string]interface{}, len(v))
+		for name, bit := range v {
+			field[name] = readingValue(bit)
+		}
+		return field
+	default:
+		return v
+	}
+}
+
+func (variable SensorVariableConfig) typeConfig() VariableTypeConfig {
+	return VariableTypeConfig{Type: variable.Type, Bits: variable.Bits}
+}
+
+// scaleValue applies the scale and offset of a variable to a numeric value.
+func scaleValue(value interface{}, variable SensorVariableConfig) (float64, error) {
+	var raw float64
+	switch v := value.(type) {
+	case uint32:
+		raw = float64(v)
+	case int32:
+		raw = float64(v)
+	case float32:
+		raw = float64(v)
+	default:
+		return 0, fmt.Errorf("cannot scale variable %s, it is not a numeric value", variable.Name)
+	}
+	scale := 1.0
+	if variable.Scale != nil {
+		scale = *variable.Scale
+	}
+	return raw*scale + variable.Offset, nil
+}
+
+func (s *revolutionPiSensor) DoCommand(ctx context.Context, req map[string]interface{}) (map[string]interface{}, error) {
+	return nil, grpc.UnimplementedError
+}
+
+func (s *revolutionPiSensor) Close(ctx context.Context) error {
+	return s.chip.Close()
+}
@@ -0,0 +1,238 @@
+//go:build linux
+
+// Package revolutionpi implements the Revolution Pi.
+package revolutionpi
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// types that variables in the process image can be decoded as.
+const (
+	typeBool     = "bool"
+	typeInt      = "int" // signed integer of the variable's length
+	typeInt8     = "int8"
+	typeInt16    = "int16"
+	typeInt32    = "int32"
+	typeUint     = "uint" // unsigned integer of the variable's length
+	typeUint8    = "uint8"
+	typeUint16   = "uint16"
+	typeUint32   = "uint32"
+	typeFloat32  = "float32"
+	typeBitField = "bitfield"
+)
+
+// VariableTypeConfig declares how a process image variable is decoded and encoded.
+// Bit fields decode into a value for each named bit, keyed by the name and holding the bit position.
+type VariableTypeConfig struct {
+	Type string           `json:"type"`
+	Bits map[string]uint8 `json:"bits,omitempty"`
+}
+
+// variableType is a type resolved for a specific variable.
+type variableType struct {
+	name   string
+	size   int  // size in bytes
+	signed bool // only used for integers
+	bits   map[string]uint8
+}
+
+// Validate checks that the type is known.
+func (cfg VariableTypeConfig) Validate() error {
+	switch cfg.Type {
+	case typeBool, typeInt, typeInt8, typeInt16, typeInt32, typeUint, typeUint8, typeUint16, typeUint32, typeFloat32:
+		if len(cfg.Bits) != 0 {
+			return fmt.Errorf("bits can only be named for type %s", typeBitField)
+		}
+	case typeBitField:
+		if len(cfg.Bits) == 0 {
+			return fmt.Errorf("type %s requires named bits", typeBitField)
+		}
+		for name, bit := range cfg.Bits {
+			if bit > 31 {
+				return fmt.Errorf("bit %s must be between 0 and 31, got %d", name, bit)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown variable type %q", cfg.Type)
+	}
+	return nil
+}
+
+// resolve returns the type for a variable of the given length in bits.
+func (cfg VariableTypeConfig) resolve(length uint16) (variableType, error) {
+	sizeFromLength := max(int(length+7)/8, 1)
+	switch cfg.Type {
+	case typeBool:
+		return variableType{name: typeBool, size: 1}, nil
+	case typeInt8, typeUint8:
+		return variableType{name: cfg.Type, size: 1, signed: cfg.Type == typeInt8}, nil
+	case typeInt16, typeUint16:
+		return variableType{name: cfg.Type, size: 2, signed: cfg.Type == typeInt16}, nil
+	case typeInt32, typeUint32:
+		return variableType{name: cfg.Type, size: 4, signed: cfg.Type == typeInt32}, nil
+	case typeFloat32:
+		return variableType{name: typeFloat32, size: 4}, nil
+	case typeInt, typeUint:
+		if sizeFromLength != 1 && sizeFromLength != 2 && sizeFromLength != 4 {
+			return variableType{}, fmt.Errorf("cannot use type %s with a %d bit variable", cfg.Type, length)
+		}
+		return variableType{name: cfg.Type, size: sizeFromLength, signed: cfg.Type == typeInt}, nil
+	case typeBitField:
+		if sizeFromLength != 1 && sizeFromLength != 2 && sizeFromLength != 4 {
+			return variableType{}, fmt.Errorf("cannot use type %s with a %d bit variable", cfg.Type, length)
+		}
+		for name, bit := range cfg.Bits {
+			if int(bit) >= sizeFromLength*8 {
+				return variableType{}, fmt.Errorf("bit %s is outside of the %d bit variable", name, length)
+			}
+		}
+		return variableType{name: typeBitField, size: sizeFromLength, bits: cfg.Bits}, nil
+	default:
+		return variableType{}, fmt.Errorf("unknown variable type %q", cfg.Type)
+	}
+}
+
+// typeOf returns the type used to decode a variable. Types declared in the config take priority,
+// otherwise the type is inferred from the PiCtory configuration and the length of the variable.
+func (g *gpioChip) typeOf(pin SPIVariable) (variableType, error) {
+	name := str32(pin.strVarName)
+	if cfg, ok := g.variableTypes[name]; ok {
+		return cfg.resolve(pin.i16uLength)
+	}
+	if pin.i16uLength == 1 {
+		return variableType{name: typeBool, size: 1}, nil
+	}
+	if variable, ok := g.getPiCtoryVariable(name); ok && isSignedAIOValue(variable) {
+		return variableType{name: typeInt16, size: 2, signed: true}, nil
+	}
+	return VariableTypeConfig{Type: typeUint}.resolve(pin.i16uLength)
+}
+
+// isSignedAIOValue checks whether a variable is one of the signed analog values of an AIO module:
+// InputValue_1-4 and RTDValue_1-2 at input offsets 0-11, and OutputValue_1-2 at the first output offsets.
+func isSignedAIOValue(variable piCtoryVariable) bool {
+	if variable.ProductType != 103 || variable.Length != 16 {
+		return false
+	}
+	switch variable.Section {
+	case "inp":
+		return variable.deviceOffset < 12
+	case "out":
+		return true
+	default:
+		return false
+	}
+}
+
+// decodeValue decodes a variable from the bytes read at its address.
+func decodeValue(t variableType, buf []byte, bit uint8) (interface{}, error) {
+	if len(buf) < t.size {
+		return nil, fmt.Errorf("expected %d bytes, got %d", t.size, len(buf))
+	}
+	buf = buf[:t.size]
+	switch t.name {
+	case typeBool:
+		if bit < 8 {
+			return (buf[0]>>bit)&1 == 1, nil
+		}
+		return buf[0] != 0, nil
+	case typeFloat32:
+		return math.Float32frombits(binary.LittleEndian.Uint32(buf)), nil
+	case typeBitField:
+		raw := readUint(buf)
+		field := map[string]interface{}{"raw": raw}
+		for name, pos := range t.bits {
+			field[name] = (raw>>pos)&1 == 1
+		}
+		return field, nil
+	}
+	// integers are widened to 32 bits, as protobuf, and so the readings and DoCommand responses, only carry
+	// 32 and 64 bit integers
+	if t.signed {
+		switch t.size {
+		case 1:
+			return int32(int8(buf[0])), nil
+		case 2:
+			return int32(int16(binary.LittleEndian.Uint16(buf))), nil
+		default:
+			return int32(binary.LittleEndian.Uint32(buf)), nil
+		}
+	}
+	return readUint(buf), nil
+}
+
+// encodeValue encodes a value for writing to a variable. Numbers arrive from json as float64 and must fit the type.
+// Bit fields accept either a number or a map of named bits, which are applied on top of current.
+func encodeValue(t variableType, value interface{}, current []byte) ([]byte, error) {
+	buf := make([]byte, t.size)
+	switch t.name {
+	case typeBool:
+		high, ok := value.(bool)
+		if !ok {
+			return nil, fmt.Errorf("expected bool, got %v", value)
+		}
+		if high {
+			buf[0] = 1
+		}
+		return buf, nil
+	case typeFloat32:
+		num, ok := value.(float64)
+		if !ok {
+			return nil, fmt.Errorf("expected number, got %v", value)
+		}
+		if math.Abs(num) > math.MaxFloat32 {
+			return nil, fmt.Errorf("%w: %v does not fit in a float32", ErrOutOfRange, num)
+		}
+		binary.LittleEndian.PutUint32(buf, math.Float32bits(float32(num)))
+		return buf, nil
+	case typeBitField:
+		if bits, ok := value.(map[string]interface{}); ok {
+			raw := readUint(current)
+			for name, val := range bits {
+				pos, ok := t.bits[name]
+				if !ok {
+					return nil, fmt.Errorf("unknown bit %s", name)
+				}
+				high, ok := val.(bool)
+				if !ok {
+					return nil, fmt.Errorf("expected bool for bit %s, got %v", name, val)
+				}
+				raw &^= 1 << pos
+				if high {
+					raw |= 1 << pos
+				}
+			}
+			putUint(buf, raw)
+			return buf, nil
+		}
+	}
+
+	num, ok := value.(float64)
+	if !ok || num != math.Trunc(num) {
+		return nil, fmt.Errorf("expected integer, got %v", value)
+	}
+	minVal, maxVal := 0.0, float64(uint64(1)<<(8*t.size)-1)
+	if t.signed {
+		minVal, maxVal = -float64(uint64(1)<<(8*t.size-1)), float64(uint64(1)<<(8*t.size-1)-1)
+	}
+	if num < minVal || num > maxVal {
+		return nil, fmt.Errorf("%w: %v is not within expected range (%v to %v)", ErrOutOfRange, num, minVal, maxVal)
+	}
+	putUint(buf, uint32(int64(num)))
+	return buf, nil
+}
+
+func readUint(buf []byte) uint32 {
+	padded := make([]byte, 4)
+	copy(padded, buf)
+	return binary.LittleEndian.Uint32(padded)
+}
+
+func putUint(buf []byte, val uint32) {
+	padded := make([]byte, 4)
+	binary.LittleEndian.PutUint32(padded, val)
+	copy(buf, padded)
+}
@@ -0,0 +1,297 @@
+//go:build linux
+
+// Package revolutionpi implements the Revolution Pi.
+package revolutionpi
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// policies that restrict writes to a variable.
+const (
+	writePolicyAllow = "allow"
+	writePolicyDeny  = "deny"
+	writePolicyRange = "range" // only values between min and max may be written
+)
+
+// WritePolicyConfig restricts writes to a variable.
+type WritePolicyConfig struct {
+	Policy string   `json:"policy"`
+	Min    *float64 `json:"min,omitempty"`
+	Max    *float64 `json:"max,omitempty"`
+}
+
+// Validate checks that the policy is known, and that only range policies have bounds.
+func (cfg WritePolicyConfig) Validate() error {
+	switch cfg.Policy {
+	case writePolicyAllow, writePolicyDeny:
+		if cfg.Min != nil || cfg.Max != nil {
+			return fmt.Errorf("min and max can only be set for policy %s", writePolicyRange)
+		}
+	case writePolicyRange:
+		if cfg.Min == nil && cfg.Max == nil {
+			return fmt.Errorf("policy %s requires min, max or both", writePolicyRange)
+		}
+		if cfg.Min != nil && cfg.Max != nil && *cfg.Min > *cfg.Max {
+			return fmt.Errorf("min %v is greater than max %v", *cfg.Min, *cfg.Max)
+		}
+	default:
+		return fmt.Errorf("unknown write policy %q", cfg.Policy)
+	}
+	return nil
+}
+
+// allows checks whether the policy allows value to be written. It returns an error explaining why it does not.
+func (cfg WritePolicyConfig) allows(name string, value interface{}) error {
+	switch cfg.Policy {
+	case writePolicyDeny:
+		return fmt.Errorf("writing %s is denied", name)
+	case writePolicyRange:
+		num, ok := numericValue(value)
+		if !ok {
+			return fmt.Errorf("%s has a %s policy but %v is not a number", name, writePolicyRange, value)
+		}
+		if (cfg.Min != nil && num < *cfg.Min) || (cfg.Max != nil && num > *cfg.Max) {
+			return fmt.Errorf("%v is outside the allowed range of %s (%s)", num, name, cfg.rangeString())
+		}
+	}
+	return nil
+}
+
+func (cfg WritePolicyConfig) rangeString() string {
+	switch {
+	case cfg.Min == nil:
+		return fmt.Sprintf("at most %v", *cfg.Max)
+	case cfg.Max == nil:
+		return fmt.Sprintf("at least %v", *cfg.Min)
+	default:
+		return fmt.Sprintf("%v to %v", *cfg.Min, *cfg.Max)
+	}
+}
+
+// numericValue converts a decoded or json value to a float64. Bit fields are compared by their raw value.
+func numericValue(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case map[string]interface{}:
+		return numericValue(v["raw"])
+	default:
+		return 0, false
+	}
+}
+
+// writePolicy enforces the read only mode and the write policies of the board on every write of its chip.
+type writePolicy struct {
+	readOnly bool
+	// only variables with an allow or range policy may be written
+	defaultDeny bool
+	policies    map[string]WritePolicyConfig
+
+	// the policies resolved to variables, for the configuration generation they were resolved for
+	mu         sync.Mutex
+	resolved   bool
+	generation uint64
+	rules      []writeRule
+	// why a policy cannot be resolved, which rejects every write as the variables it protects are unknown
+	err error
+}
+
+// writeRule is a write policy resolved to the variable it applies to.
+type writeRule struct {
+	name   string
+	pin    SPIVariable
+	mask   []byte // the bits of the variable in the bytes starting at its address
+	policy WritePolicyConfig
+}
+
+func newWritePolicy(readOnly, defaultDeny bool, policies map[string]WritePolicyConfig) *writePolicy {
+	return &writePolicy{readOnly: readOnly, defaultDeny: defaultDeny, policies: policies}
+}
+
+// writeRules resolves the write policies to their variables, again whenever the configuration is reloaded.
+// It returns an error if a policy cannot be resolved.
+func (g *gpioChip) writeRules() ([]writeRule, error) {
+	p := g.policy
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	generation := g.configGeneration.Load()
+	if p.resolved && p.generation == generation {
+		return p.rules, p.err
+	}
+
+	names := make([]string, 0, len(p.policies))
+	for name := range p.policies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	rules := make([]writeRule, 0, len(names))
+	var resolveErr error
+	for _, name := range names {
+		pin := SPIVariable{strVarName: char32(name)}
+		if err := g.mapNameToAddress(&pin); err != nil {
+			// writes are rejected rather than allowed without the policy
+			g.logger.Errorf("the write policy of %s cannot be applied, every write will be rejected: %v", name, err)
+			resolveErr = fmt.Errorf("the write policy of %s cannot be applied: %w", name, err)
+			break
+		}
+		rules = append(rules, writeRule{name: name, pin: pin, mask: variableMask(pin), policy: p.policies[name]})
+	}
+	p.rules, p.err, p.generation, p.resolved = rules, resolveErr, generation, true
+	return rules, resolveErr
+}
+
+// variableMask returns the bits of a variable in the bytes starting at its address.
+func variableMask(pin SPIVariable) []byte {
+	if pin.i16uLength == 1 {
+		return []byte{1 << pin.i8uBit}
+	}
+	mask := make([]byte, (pin.i16uLength+7)/8)
+	for i := range mask {
+		mask[i] = 0xff
+	}
+	return mask
+}
+
+// checkWrite checks the bits in mask of b, about to be written at address, against the write policy and the
+// interlocks of the board. It returns an error wrapping ErrWriteDenied if the bits belong to a variable the board
+// does not allow to be written with its new value, or ErrInterlocked if the write violates an interlock.
+// A nil mask checks every bit of b. Rejected writes are logged.
+func (g *gpioChip) checkWrite(address int64, b, mask []byte) error {
+	if g.policy == nil && g.interlocks == nil {
+		return nil
+	}
+	if mask == nil {
+		mask = make([]byte, len(b))
+		for i := range mask {
+			mask[i] = 0xff
+		}
+	}
+	if g.policy != nil {
+		if err := g.policyError(address, b, mask); err != nil {
+			err = fmt.Errorf("%w: %w", ErrWriteDenied, err)
+			g.logger.Warnf("denied a write of %d byte(s) at address %d: %v", len(b), address, err)
+			return err
+		}
+	}
+	if g.interlocks != nil {
+		if err := g.interlockError(address, b, mask); err != nil {
+			err = fmt.Errorf("%w: %w", ErrInterlocked, err)
+			g.logger.Warnf("rejected a write of %d byte(s) at address %d: %v", len(b), address, err)
+			return err
+		}
+	}
+	return nil
+}
+
+// masksOverlap checks whether the bits of a variable at varAddress overlap the bits in mask at address.
+func masksOverlap(varAddress uint16, varMask []byte, address int64, mask []byte) bool {
+	for i, bits := range varMask {
+		pos := int64(varAddress) + int64(i) - address
+		if pos >= 0 && pos < int64(len(mask)) && mask[pos]&bits != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// policyError explains why the write policy does not allow a write, or returns nil if it does.
+func (g *gpioChip) policyError(address int64, b, mask []byte) error {
+	if g.policy.readOnly {
+		return errors.New("the board is read only")
+	}
+
+	// bits covered by an allow or range policy, for boards that deny writes by default
+	covered := make([]byte, len(mask))
+	rules, err := g.writeRules()
+	if err != nil {
+		return err
+	}
+	for _, rule := range rules {
+		overlaps := false
+		for i, bits := range rule.mask {
+			pos := int64(rule.pin.i16uAddress) + int64(i) - address
+			if pos < 0 || pos >= int64(len(mask)) || mask[pos]&bits == 0 {
+				continue
+			}
+			overlaps = true
+			covered[pos] |= bits
+		}
+		if !overlaps {
+			continue
+		}
+		value, err := g.writtenValue(rule.pin, address, b, mask)
+		if err != nil {
+			return fmt.Errorf("unable to check the write policy of %s: %w", rule.name, err)
+		}
+		if err := rule.policy.allows(rule.name, value); err != nil {
+			return err
+		}
+	}
+
+	if !g.policy.defaultDeny {
+		return nil
+	}
+	for i := range mask {
+		uncovered := mask[i] &^ covered[i]
+		for bit := uint8(0); bit < 8; bit++ {
+			if uncovered&(1<<bit) != 0 {
+				return fmt.Errorf("%s has no write policy allowing it to be written", g.variableAt(uint16(address)+uint16(i), bit))
+			}
+		}
+	}
+	return nil
+}
+
+// writtenValue decodes the value a variable will have once the bits in mask of b are written at address.
+// Bits that are not written keep their current value, or their forced value if they are forced.
+func (g *gpioChip) writtenValue(pin SPIVariable, address int64, b, mask []byte) (interface{}, error) {
+	t, err := g.typeOf(pin)
+	if err != nil {
+		return nil, err
+	}
+	value := make([]byte, t.size)
+	if _, err := g.readAt(value, int64(pin.i16uAddress), true); err != nil {
+		return nil, err
+	}
+	for i := range value {
+		pos := int64(pin.i16uAddress) + int64(i) - address
+		if pos < 0 || pos >= int64(len(b)) {
+			continue
+		}
+		value[i] = value[i]&^mask[pos] | b[pos]&mask[pos]
+	}
+	return decodeValue(t, value, pin.i8uBit)
+}
+
+// variableAt names the PiCtory variable holding a bit of the process image, or the bit itself if there is none.
+func (g *gpioChip) variableAt(address uint16, bit uint8) string {
+	best := ""
+	for name, variable := range g.getPiCtoryVariables() {
+		if variable.Length == 1 {
+			if variable.Address == address && variable.Bit == bit {
+				return name
+			}
+			continue
+		}
+		if address >= variable.Address && uint32(address) < uint32(variable.Address)+uint32(variable.Length+7)/8 {
+			// prefer the same name on every call when variables overlap
+			if best == "" || name < best {
+				best = name
+			}
+		}
+	}
+	if best != "" {
+		return best
+	}
+	return fmt.Sprintf("bit %d at address %d", bit, address)
+}